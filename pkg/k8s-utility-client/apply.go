@@ -0,0 +1,616 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/retry"
+	"sync"
+)
+
+// DEFAULT_FIELD_MANAGER  The field manager recorded by server-side apply when ApplyOptions doesn't specify one.
+const DEFAULT_FIELD_MANAGER = "k8s-utility-client"
+
+// ApplyAction  What applying an object did to the cluster.
+type ApplyAction string
+
+const (
+	// APPLY_CREATED  The object didn't exist, and was created.
+	APPLY_CREATED ApplyAction = "Created"
+	// APPLY_UPDATED  The object existed, and was changed.
+	APPLY_UPDATED ApplyAction = "Updated"
+	// APPLY_UNCHANGED  The object existed, and the server found nothing to change.
+	APPLY_UNCHANGED ApplyAction = "Unchanged"
+)
+
+// ObjectResult  The outcome of applying a single object.
+type ObjectResult struct {
+	// Action  Whether the object was created, updated, or left unchanged.
+	Action ApplyAction
+	// Object  The object as returned by the server.
+	Object *unstructured.Unstructured
+}
+
+// ApplyResult  The outcome of ApplyResourcesWithResult, one ObjectResult per object, in the order applied.
+type ApplyResult struct {
+	Objects []ObjectResult
+}
+
+// Count  Returns how many objects had the given outcome.
+func (r ApplyResult) Count(action ApplyAction) (count int) {
+	for _, o := range r.Objects {
+		if o.Action == action {
+			count++
+		}
+	}
+
+	return count
+}
+
+// String  Summarizes the result, e.g. "3 created, 1 updated, 0 unchanged".
+func (r ApplyResult) String() string {
+	return fmt.Sprintf("%d created, %d updated, %d unchanged", r.Count(APPLY_CREATED), r.Count(APPLY_UPDATED), r.Count(APPLY_UNCHANGED))
+}
+
+// ApplyOptions  Controls how ApplyResourcesWithOptions applies objects to the cluster.  The zero value behaves exactly like ApplyResources.
+type ApplyOptions struct {
+	// ServerSide  Use server-side apply instead of Get-then-Create/Update.  The server merges the object with fields owned by other managers, and handles create-or-update in a single call.
+	ServerSide bool
+	// FieldManager  The field manager to record for server-side apply.  Defaults to DEFAULT_FIELD_MANAGER.
+	FieldManager string
+	// Force  Take ownership of fields another field manager owns, rather than failing with a conflict, like `kubectl apply --server-side --force-conflicts`.  Only used with ServerSide.
+	Force bool
+	// DryRun  Send every write with DryRun=All.  The server validates and admits the objects as usual, but nothing is persisted.
+	DryRun bool
+	// SortByKind  Apply foundational kinds such as Namespaces and CRDs before everything else.  See SortObjectsByKind.
+	SortByKind bool
+	// EnsureNamespace  Create any namespace the objects are destined for that doesn't exist yet, before applying anything.  See EnsureNamespaceExists.
+	EnsureNamespace bool
+	// Labels  Labels to add to every object before applying.  See AddLabels.
+	Labels map[string]string
+	// Annotations  Annotations to add to every object before applying.  See AddAnnotations.
+	Annotations map[string]string
+	// ThreeWayMerge  Update existing objects with a client-side three-way merge, like `kubectl apply`, rather than replacing them wholesale.  The last applied configuration is kept in the kubectl.kubernetes.io/last-applied-configuration annotation, so fields dropped from the manifest are removed from the live object, while fields set by other controllers are left alone.  Ignored if ServerSide is set.
+	ThreeWayMerge bool
+	// SkipUnchanged  Don't Update objects whose content already matches the live object, as judged by ObjectHash, and report them as Unchanged.  Saves needless writes, and resourceVersion bumps, when reconcile loops re-apply the same objects.  Anything the server filled in, such as defaulted fields, makes the live object differ, in which case it's updated as usual.  Only used by the default create-or-update apply.
+	SkipUnchanged bool
+	// OnlyNamespaces  Only apply objects destined for these namespaces, skipping the rest, e.g. to keep tenant-scoped tooling within its own namespaces.  Cluster-scoped objects are always applied.  Empty means every namespace.
+	OnlyNamespaces []string
+	// Tiered  Apply in tiers: Namespaces, then CRDs, then everything else.  Each tier's Namespaces must be Active, and its CRDs established, before the next tier is applied.  Where SortByKind only orders the objects, this waits for them too.
+	Tiered bool
+	// ContinueOnError  Carry on applying the rest of the objects when one fails, rather than stopping at the first failure.  Every failure is returned together in a utilerrors.Aggregate, so you can report all of them.
+	ContinueOnError bool
+	// OnProgress  Called twice for each object: before applying it, with action "Applying", and afterwards with the ApplyAction taken, e.g. "Created".  index counts from 0 to total-1.  Handy for progress bars, or logging in tests.
+	OnProgress func(index int, total int, obj *unstructured.Unstructured, action string)
+}
+
+// APPLY_STARTING  The action passed to OnProgress just before an object is applied.
+const APPLY_STARTING = "Applying"
+
+// progress  Calls OnProgress, if there is one.
+func (o ApplyOptions) progress(index int, total int, obj *unstructured.Unstructured, action string) {
+	if o.OnProgress != nil {
+		o.OnProgress(index, total, obj, action)
+	}
+}
+
+// dryRun  Returns the DryRun value for the write options of an API call.
+func (o ApplyOptions) dryRun() (dryRun []string) {
+	if o.DryRun {
+		dryRun = []string{metav1.DryRunAll}
+	}
+
+	return dryRun
+}
+
+// ApplyResourcesWithOptions  Applies a list of Unstructured interfaces and 'objects' to the cluster like ApplyResources, but lets the caller choose how via ApplyOptions.
+func (k *K8sClients) ApplyResourcesWithOptions(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, opts ApplyOptions) (err error) {
+	_, err = k.ApplyResourcesWithResult(ctx, interfaces, objects, opts)
+
+	return err
+}
+
+// ApplyResourcesWithResult  Applies objects exactly like ApplyResourcesWithOptions, and reports what happened to each one.  On error, the result covers the objects applied before the failure.
+func (k *K8sClients) ApplyResourcesWithResult(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, opts ApplyOptions) (result ApplyResult, err error) {
+	result.Objects = make([]ObjectResult, 0)
+
+	if len(opts.OnlyNamespaces) > 0 {
+		interfaces, objects = filterObjects(interfaces, objects, inNamespaces(opts.OnlyNamespaces))
+	}
+
+	if opts.Tiered {
+		return k.applyTiered(ctx, interfaces, objects, opts)
+	}
+
+	if opts.SortByKind {
+		interfaces, objects = SortObjectsByKind(interfaces, objects)
+	}
+
+	if len(opts.Labels) > 0 {
+		AddLabels(objects, opts.Labels)
+	}
+
+	if len(opts.Annotations) > 0 {
+		AddAnnotations(objects, opts.Annotations)
+	}
+
+	if opts.EnsureNamespace {
+		seen := make(map[string]bool)
+
+		for _, obj := range objects {
+			namespace := obj.GetNamespace()
+			if namespace == "" || seen[namespace] {
+				continue
+			}
+
+			seen[namespace] = true
+
+			err = k.ensureNamespaceExists(ctx, namespace, opts.dryRun())
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	// only used with ContinueOnError
+	errs := make([]error, 0)
+
+	for i, ri := range interfaces {
+		obj := objects[i]
+
+		// stop promptly if we've been cancelled, rather than pressing on until the next API call notices
+		select {
+		case <-ctx.Done():
+			err = errors.Wrapf(ctx.Err(), "stopped applying resources at %s kind %s", obj.GetName(), obj.GetKind())
+			if opts.ContinueOnError {
+				err = utilerrors.NewAggregate(append(errs, err))
+			}
+
+			return result, err
+		default:
+		}
+
+		opts.progress(i, len(objects), obj, APPLY_STARTING)
+
+		var applied *unstructured.Unstructured
+		var action ApplyAction
+
+		switch {
+		case opts.ServerSide:
+			applied, action, err = serverSideApply(ctx, ri, obj, opts)
+		case opts.ThreeWayMerge:
+			applied, action, err = threeWayMergeApply(ctx, ri, obj, opts)
+		default:
+			applied, action, err = createOrUpdate(ctx, ri, obj, opts)
+		}
+
+		if err != nil {
+			if opts.ContinueOnError {
+				errs = append(errs, err)
+				continue
+			}
+
+			return result, err
+		}
+
+		result.Objects = append(result.Objects, ObjectResult{Action: action, Object: applied})
+		opts.progress(i, len(objects), obj, string(action))
+
+		// Custom resources can't be applied until the CRD defining them is established.  A dry run never creates the CRD, so there's nothing to wait for.
+		if isCRD(obj) && !opts.DryRun && crdDefinesAny(obj, objects[i+1:]) {
+			err = k.WaitForCRDEstablished(ctx, obj.GetName(), CRD_ESTABLISHED_TIMEOUT)
+			if err != nil {
+				if opts.ContinueOnError {
+					errs = append(errs, err)
+					continue
+				}
+
+				return result, err
+			}
+		}
+	}
+
+	err = utilerrors.NewAggregate(errs)
+
+	return result, err
+}
+
+// ApplyObject  Applies a single object, such as one built in code, without the bother of loading it into parallel slices first.  The object's kind is resolved against the cluster, namespaced objects without a namespace go into "default", and the object is created or updated just like ApplyResources would.  Returns the object as stored by the server.
+func (k *K8sClients) ApplyObject(ctx context.Context, obj *unstructured.Unstructured) (applied *unstructured.Unstructured, err error) {
+	ri, err := k.ResourceInterfaceForObject(obj)
+	if err != nil {
+		return applied, err
+	}
+
+	result, err := k.ApplyResourcesWithResult(ctx, []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{obj}, ApplyOptions{})
+	if err != nil {
+		return applied, err
+	}
+
+	applied = result.Objects[0].Object
+
+	return applied, err
+}
+
+// ApplyTypedObject  Applies a typed object, such as an *appsv1.Deployment or a controller-runtime client.Object, by converting it to Unstructured and handing it to ApplyObject.  Typed objects built in code usually leave apiVersion and kind empty, so they're filled in from the client-go scheme when missing.
+func (k *K8sClients) ApplyTypedObject(ctx context.Context, obj runtime.Object) (err error) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil {
+			err = errors.Wrapf(err, "failed determining kind of %T", obj)
+			return err
+		}
+
+		gvk = gvks[0]
+	}
+
+	unstructuredMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		err = errors.Wrapf(err, "failed converting %T to unstructured", obj)
+		return err
+	}
+
+	u := &unstructured.Unstructured{Object: unstructuredMap}
+	u.SetGroupVersionKind(gvk)
+
+	_, err = k.ApplyObject(ctx, u)
+
+	return err
+}
+
+// ApplyFromFile  Loads the resources in a yaml or json file, and applies them with the given options, like `kubectl apply -f`.
+func (k *K8sClients) ApplyFromFile(ctx context.Context, fileName string, opts ApplyOptions) (err error) {
+	interfaces, objects, err := k.ResourcesAndObjectsFromFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	err = k.ApplyResourcesWithOptions(ctx, interfaces, objects, opts)
+
+	return err
+}
+
+// createOrUpdate  Tries to Get the object first.  If it already exists, it's Updated, otherwise it's Created.
+func createOrUpdate(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured, opts ApplyOptions) (result *unstructured.Unstructured, action ApplyAction, err error) {
+	// Try to get the resource from k8s.  If it exists, we'll have to update, and cope with the optimistic lock
+	res, getErr := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if getErr == nil {
+		return updateExisting(ctx, ri, obj, res, opts)
+	}
+
+	existed, err := retryCreate(ctx, func() (err error) {
+		result, err = ri.Create(ctx, obj, metav1.CreateOptions{
+			DryRun: opts.dryRun(),
+		})
+
+		return err
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating %s kind %s", obj.GetName(), obj.GetKind())
+		return result, action, err
+	}
+
+	if !existed {
+		return result, APPLY_CREATED, err
+	}
+
+	// A create that failed transiently went through after all, or someone beat us to it.  Either way it's there now, so make sure it's what we want.
+	res, err = ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting %s kind %s", obj.GetName(), obj.GetKind())
+		return result, action, err
+	}
+
+	result, action, err = updateExisting(ctx, ri, obj, res, opts)
+
+	// if the update didn't need to change anything, it was our create that went through
+	if err == nil && action == APPLY_UNCHANGED {
+		action = APPLY_CREATED
+	}
+
+	return result, action, err
+}
+
+// updateExisting  Updates an object that's already in the cluster, as res, with the desired obj.
+func updateExisting(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured, res *unstructured.Unstructured, opts ApplyOptions) (result *unstructured.Unstructured, action ApplyAction, err error) {
+	if opts.SkipUnchanged {
+		unchanged, err := sameContent(obj, res)
+		if err != nil {
+			return result, action, err
+		}
+
+		if unchanged {
+			return res, APPLY_UNCHANGED, err
+		}
+	}
+
+	// Another writer can bump the resourceVersion between our Get and Update.  If so, re-Get the latest and try again.
+	attempt := 0
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		if attempt > 0 {
+			res, err = ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+		}
+
+		attempt++
+
+		rv := res.GetResourceVersion()
+		obj.SetResourceVersion(rv)
+
+		err = retryTransient(ctx, func() (err error) {
+			result, err = ri.Update(ctx, obj, metav1.UpdateOptions{
+				DryRun: opts.dryRun(),
+			})
+
+			return err
+		})
+
+		return err
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed updating %s kind %s", obj.GetName(), obj.GetKind())
+		return result, action, err
+	}
+
+	action = updateAction(res, result)
+
+	return result, action, err
+}
+
+// updateAction  Works out whether a write to an existing object changed it.  The server only bumps the resourceVersion when something actually changed.
+func updateAction(before *unstructured.Unstructured, after *unstructured.Unstructured) (action ApplyAction) {
+	if after != nil && before.GetResourceVersion() == after.GetResourceVersion() {
+		return APPLY_UNCHANGED
+	}
+
+	return APPLY_UPDATED
+}
+
+// serverSideApply  Applies the object with a server-side apply patch.  The server creates the object if it doesn't exist, and merges it with the live object if it does.
+func serverSideApply(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured, opts ApplyOptions) (result *unstructured.Unstructured, action ApplyAction, err error) {
+	fieldManager := opts.FieldManager
+	if fieldManager == "" {
+		fieldManager = DEFAULT_FIELD_MANAGER
+	}
+
+	// A resourceVersion in an apply patch is treated as a precondition, and managedFields are owned by the server, so neither belongs in the request.
+	applyObj := obj.DeepCopy()
+	applyObj.SetResourceVersion("")
+	applyObj.SetManagedFields(nil)
+
+	data, err := applyObj.MarshalJSON()
+	if err != nil {
+		err = errors.Wrapf(err, "failed marshalling %s kind %s", obj.GetName(), obj.GetKind())
+		return result, action, err
+	}
+
+	// The apply patch itself doesn't say whether it created the object, so look first
+	existing, getErr := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+
+	patchOpts := metav1.PatchOptions{
+		FieldManager: fieldManager,
+		DryRun:       opts.dryRun(),
+	}
+
+	if opts.Force {
+		force := true
+		patchOpts.Force = &force
+	}
+
+	err = retryTransient(ctx, func() (err error) {
+		result, err = ri.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, patchOpts)
+		return err
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed server-side applying %s kind %s", obj.GetName(), obj.GetKind())
+		return result, action, err
+	}
+
+	if getErr != nil {
+		return result, APPLY_CREATED, err
+	}
+
+	action = updateAction(existing, result)
+
+	return result, action, err
+}
+
+// threeWayMergeApply  Applies the object the way `kubectl apply` does.  New objects are created with the last-applied-configuration annotation.  Existing objects are patched with a three-way merge of the last applied configuration, the desired object, and the live object.  Built-in kinds get a strategic merge patch, anything else a JSON merge patch.
+func threeWayMergeApply(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured, opts ApplyOptions) (result *unstructured.Unstructured, action ApplyAction, err error) {
+	modified, err := setLastApplied(obj)
+	if err != nil {
+		return result, action, err
+	}
+
+	// set when a retried create turns out to have gone through
+	var created bool
+
+	live, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			err = errors.Wrapf(err, "failed getting %s kind %s", obj.GetName(), obj.GetKind())
+			return result, action, err
+		}
+
+		existed, err := retryCreate(ctx, func() (err error) {
+			result, err = ri.Create(ctx, obj, metav1.CreateOptions{
+				DryRun: opts.dryRun(),
+			})
+
+			return err
+		})
+		if err != nil {
+			err = errors.Wrapf(err, "failed creating %s kind %s", obj.GetName(), obj.GetKind())
+			return result, action, err
+		}
+
+		if !existed {
+			return result, APPLY_CREATED, err
+		}
+
+		// A create that failed transiently went through after all, or someone beat us to it.  Patch whatever's there now, as though it always had been.
+		live, err = ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			err = errors.Wrapf(err, "failed getting %s kind %s", obj.GetName(), obj.GetKind())
+			return result, action, err
+		}
+
+		created = true
+	}
+
+	original := []byte(live.GetAnnotations()[corev1.LastAppliedConfigAnnotation])
+
+	current, err := live.MarshalJSON()
+	if err != nil {
+		err = errors.Wrapf(err, "failed marshalling live %s kind %s", obj.GetName(), obj.GetKind())
+		return result, action, err
+	}
+
+	var patch []byte
+	var patchType types.PatchType
+
+	// Strategic merge needs the Go type to know how to merge lists.  Kinds we don't have a type for, such as custom resources, fall back on a JSON merge patch.
+	typed, schemeErr := scheme.Scheme.New(obj.GroupVersionKind())
+	if schemeErr == nil {
+		patchType = types.StrategicMergePatchType
+
+		patchMeta, err := strategicpatch.NewPatchMetaFromStruct(typed)
+		if err != nil {
+			err = errors.Wrapf(err, "failed getting patch metadata for kind %s", obj.GetKind())
+			return result, action, err
+		}
+
+		patch, err = strategicpatch.CreateThreeWayMergePatch(original, modified, current, patchMeta, true)
+		if err != nil {
+			err = errors.Wrapf(err, "failed computing patch for %s kind %s", obj.GetName(), obj.GetKind())
+			return result, action, err
+		}
+	} else {
+		patchType = types.MergePatchType
+
+		patch, err = jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, current)
+		if err != nil {
+			err = errors.Wrapf(err, "failed computing patch for %s kind %s", obj.GetName(), obj.GetKind())
+			return result, action, err
+		}
+	}
+
+	if string(patch) == "{}" {
+		// nothing to patch after a retried create means it was our create that went through
+		if created {
+			return live, APPLY_CREATED, err
+		}
+
+		return live, APPLY_UNCHANGED, err
+	}
+
+	err = retryTransient(ctx, func() (err error) {
+		result, err = ri.Patch(ctx, obj.GetName(), patchType, patch, metav1.PatchOptions{
+			DryRun: opts.dryRun(),
+		})
+
+		return err
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed patching %s kind %s", obj.GetName(), obj.GetKind())
+		return result, action, err
+	}
+
+	action = updateAction(live, result)
+
+	return result, action, err
+}
+
+// setLastApplied  Records the object's configuration in its last-applied-configuration annotation, the same way kubectl does, and returns the JSON of the annotated object.
+func setLastApplied(obj *unstructured.Unstructured) (modified []byte, err error) {
+	// the recorded configuration mustn't contain a previous copy of itself
+	config := obj.DeepCopy()
+	annotations := config.GetAnnotations()
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	config.SetAnnotations(annotations)
+
+	lastApplied, err := config.MarshalJSON()
+	if err != nil {
+		err = errors.Wrapf(err, "failed marshalling %s kind %s", obj.GetName(), obj.GetKind())
+		return modified, err
+	}
+
+	annotations = obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	annotations[corev1.LastAppliedConfigAnnotation] = string(lastApplied)
+	obj.SetAnnotations(annotations)
+
+	modified, err = obj.MarshalJSON()
+	if err != nil {
+		err = errors.Wrapf(err, "failed marshalling %s kind %s", obj.GetName(), obj.GetKind())
+		return modified, err
+	}
+
+	return modified, err
+}
+
+// ApplyResourcesConcurrent  Applies objects like ApplyResources, but up to maxConcurrency at a time, which is much quicker for large manifests.  There are no ordering guarantees, so objects that depend on one another must be applied separately, e.g. apply the output of SortObjectsByKind in tiers.  Every object is attempted, and the error aggregates every failure.
+func (k *K8sClients) ApplyResourcesConcurrent(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, maxConcurrency int) (err error) {
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+
+	errs := make([]error, len(objects))
+	work := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < maxConcurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			// the dynamic client is safe for concurrent use, and each object is only ever touched by one worker
+			for i := range work {
+				_, _, errs[i] = createOrUpdate(ctx, interfaces[i], objects[i], ApplyOptions{})
+			}
+		}()
+	}
+
+	for i := range objects {
+		if ctx.Err() != nil {
+			errs[i] = errors.Wrapf(ctx.Err(), "stopped applying resources at %s kind %s", objects[i].GetName(), objects[i].GetKind())
+			continue
+		}
+
+		work <- i
+	}
+
+	close(work)
+	wg.Wait()
+
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,1154 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"strings"
+	"testing"
+)
+
+func TestApplyResourcesServerSide(t *testing.T) {
+	testCases := []struct {
+		name         string
+		fileName     string
+		fieldManager string
+	}{
+		{
+			"default field manager",
+			"test_fixtures/configmap.yaml",
+			"",
+		},
+		{
+			"custom field manager",
+			"test_fixtures/configmap.yaml",
+			"utility-client-test",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+			opts := ApplyOptions{ServerSide: true, FieldManager: tc.fieldManager}
+
+			// applying twice should neither conflict nor fail because the object already exists
+			for i := 0; i < 2; i++ {
+				fmt.Printf("Server-side applying resources in k8s.  Pass %d.\n", i+1)
+				err = client.ApplyResourcesWithOptions(ctx, interfaces, objects, opts)
+				if err != nil {
+					t.Errorf("failed to server-side apply resources on pass %d: %s", i+1, err)
+				}
+			}
+
+			expectedManager := tc.fieldManager
+			if expectedManager == "" {
+				expectedManager = DEFAULT_FIELD_MANAGER
+			}
+
+			for i, obj := range objects {
+				o, err := interfaces[i].Get(ctx, obj.GetName(), metav1.GetOptions{})
+				if err != nil {
+					t.Errorf("failed getting resource %s kind %s", obj.GetName(), obj.GetKind())
+					continue
+				}
+
+				managers := make([]string, 0)
+				for _, mf := range o.GetManagedFields() {
+					managers = append(managers, mf.Manager)
+				}
+
+				assert.Contains(t, managers, expectedManager, "Server-side apply did not record the field manager.")
+			}
+
+			fmt.Printf("Cleaning up resources in k8s.\n")
+			err = client.DeleteResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Errorf("failed deleting resources: %s", err)
+			}
+		})
+	}
+}
+
+func TestApplyResourcesServerSideForce(t *testing.T) {
+	testCases := []struct {
+		name   string
+		force  bool
+		errors bool
+		value  string
+	}{
+		{
+			"conflict",
+			false,
+			true,
+			"first",
+		},
+		{
+			"forced",
+			true,
+			false,
+			"second",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			ctx := context.TODO()
+
+			first := withData(testConfigMap("utility-client-force", "default"), "first")
+			ri, err := client.ResourceInterfaceForObject(first)
+			if err != nil {
+				t.Fatalf("failed mapping object: %s", err)
+			}
+
+			err = client.ApplyResourcesWithOptions(ctx, []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{first}, ApplyOptions{ServerSide: true, FieldManager: "first-manager"})
+			if err != nil {
+				t.Fatalf("failed to server-side apply as the first manager: %s", err)
+			}
+
+			// a second manager setting the same field conflicts with the first, unless it forces the issue
+			second := withData(testConfigMap("utility-client-force", "default"), "second")
+			err = client.ApplyResourcesWithOptions(ctx, []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{second}, ApplyOptions{ServerSide: true, FieldManager: "second-manager", Force: tc.force})
+			if tc.errors {
+				assert.True(t, IsConflict(err), "Expected a conflict between field managers.  Got: %s", err)
+			} else {
+				assert.NoError(t, err, "Forced server-side apply failed.")
+			}
+
+			live, err := ri.Get(ctx, "utility-client-force", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed getting resource: %s", err)
+			}
+
+			value, _, _ := unstructured.NestedString(live.Object, "data", "foo")
+			assert.Equal(t, tc.value, value, "Live value does not match expectations.")
+
+			err = client.DeleteObject(ctx, live, metav1.DeleteOptions{})
+			if err != nil {
+				t.Errorf("failed deleting resource: %s", err)
+			}
+		})
+	}
+}
+
+// patchRecordingResourceInterface  Wraps a ResourceInterface, recording the options of every Patch call, and answering it with the patched object as sent, since the fake client can't do server-side apply.
+type patchRecordingResourceInterface struct {
+	dynamic.ResourceInterface
+	opts *[]metav1.PatchOptions
+}
+
+func (r patchRecordingResourceInterface) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (*unstructured.Unstructured, error) {
+	*r.opts = append(*r.opts, opts)
+
+	obj := &unstructured.Unstructured{}
+	err := obj.UnmarshalJSON(data)
+
+	return obj, err
+}
+
+func TestServerSideApplyForceOption(t *testing.T) {
+	testCases := []struct {
+		name     string
+		force    bool
+		expected *bool
+	}{
+		{
+			"not forced",
+			false,
+			nil,
+		},
+		{
+			"forced",
+			true,
+			func() *bool { b := true; return &b }(),
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			client := &K8sClients{DynamicClient: dc}
+
+			recorded := make([]metav1.PatchOptions, 0)
+			ri := patchRecordingResourceInterface{dc.Resource(gvr).Namespace("default"), &recorded}
+
+			err := client.ApplyResourcesWithOptions(context.TODO(), []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{testConfigMap("forced", "default")}, ApplyOptions{ServerSide: true, Force: tc.force})
+			if err != nil {
+				t.Fatalf("failed server-side applying: %s", err)
+			}
+
+			if assert.Equal(t, 1, len(recorded), "Expected exactly one patch.") {
+				assert.Equal(t, tc.expected, recorded[0].Force, "Force option does not match expectations.")
+			}
+		})
+	}
+}
+
+func TestApplyResourcesDryRun(t *testing.T) {
+	testCases := []struct {
+		name       string
+		fileName   string
+		serverSide bool
+	}{
+		{
+			"create or update",
+			"test_fixtures/configmap.yaml",
+			false,
+		},
+		{
+			"server-side",
+			"test_fixtures/configmap.yaml",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Dry-run applying resources in k8s.\n")
+			err = client.ApplyResourcesWithOptions(ctx, interfaces, objects, ApplyOptions{ServerSide: tc.serverSide, DryRun: true})
+			if err != nil {
+				t.Errorf("failed to dry-run apply resources: %s", err)
+			}
+
+			for i, obj := range objects {
+				_, err := interfaces[i].Get(ctx, obj.GetName(), metav1.GetOptions{})
+				assert.True(t, apierrors.IsNotFound(err), "Resource %s kind %s exists after a dry-run apply.", obj.GetName(), obj.GetKind())
+			}
+		})
+	}
+}
+
+func TestApplyResourcesRetriesOnConflict(t *testing.T) {
+	testCases := []struct {
+		name      string
+		conflicts int
+		errors    bool
+	}{
+		{
+			"no conflict",
+			0,
+			false,
+		},
+		{
+			"conflicts then succeeds",
+			2,
+			false,
+		},
+		{
+			"conflicts forever",
+			100,
+			true,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			live := testConfigMap("conflicted", "default")
+			live.SetResourceVersion("1")
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), live)
+
+			updates := 0
+			dc.PrependReactor("update", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				updates++
+				if updates <= tc.conflicts {
+					err = apierrors.NewConflict(gvr.GroupResource(), "conflicted", fmt.Errorf("the object has been modified"))
+					return true, nil, err
+				}
+
+				return false, nil, nil
+			})
+
+			client := &K8sClients{DynamicClient: dc}
+			interfaces := []dynamic.ResourceInterface{dc.Resource(gvr).Namespace("default")}
+			objects := []*unstructured.Unstructured{testConfigMap("conflicted", "default")}
+
+			err := client.ApplyResources(context.TODO(), interfaces, objects)
+			if tc.errors {
+				assert.True(t, apierrors.IsConflict(errors.Cause(err)), "Expected a conflict error once retries were exhausted.  Got: %s", err)
+				return
+			}
+
+			assert.NoError(t, err, "Apply did not recover from conflicts.")
+			assert.Equal(t, tc.conflicts+1, updates, "Unexpected number of update attempts.")
+		})
+	}
+}
+
+func TestApplyResourcesCancelled(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cancelAfter int
+		created     int
+	}{
+		{
+			"cancelled before starting",
+			0,
+			0,
+		},
+		{
+			"cancelled part way through",
+			1,
+			1,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if tc.cancelAfter == 0 {
+				cancel()
+			}
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+			creates := 0
+			dc.PrependReactor("create", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				creates++
+				if creates == tc.cancelAfter {
+					cancel()
+				}
+
+				return false, nil, nil
+			})
+
+			client := &K8sClients{DynamicClient: dc}
+			interfaces := make([]dynamic.ResourceInterface, 0)
+			objects := make([]*unstructured.Unstructured, 0)
+			for _, name := range []string{"one", "two", "three"} {
+				interfaces = append(interfaces, dc.Resource(gvr).Namespace("default"))
+				objects = append(objects, testConfigMap(name, "default"))
+			}
+
+			err := client.ApplyResources(ctx, interfaces, objects)
+			assert.Equal(t, context.Canceled, errors.Cause(err), "Expected the context's error.  Got: %s", err)
+			assert.Equal(t, tc.created, creates, "Apply carried on after the context was cancelled.")
+		})
+	}
+}
+
+func TestApplyResourcesWithResult(t *testing.T) {
+	testCases := []struct {
+		name     string
+		modified bool
+		second   ApplyAction
+		summary  string
+	}{
+		{
+			"reapplied unchanged",
+			false,
+			APPLY_UNCHANGED,
+			"0 created, 0 updated, 1 unchanged",
+		},
+		{
+			"reapplied with changes",
+			true,
+			APPLY_UPDATED,
+			"0 created, 1 updated, 0 unchanged",
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+			// the fake doesn't manage resourceVersions, so play the part of the API server
+			dc.PrependReactor("create", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured).SetResourceVersion("1")
+				return false, nil, nil
+			})
+
+			dc.PrependReactor("update", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				if tc.modified {
+					action.(k8stesting.UpdateAction).GetObject().(*unstructured.Unstructured).SetResourceVersion("2")
+				}
+				return false, nil, nil
+			})
+
+			client := &K8sClients{DynamicClient: dc}
+			interfaces := []dynamic.ResourceInterface{dc.Resource(gvr).Namespace("default")}
+
+			result, err := client.ApplyResourcesWithResult(context.TODO(), interfaces, []*unstructured.Unstructured{testConfigMap("result", "default")}, ApplyOptions{})
+			if err != nil {
+				t.Fatalf("failed applying resources: %s", err)
+			}
+
+			assert.Equal(t, 1, len(result.Objects), "Unexpected number of results.")
+			assert.Equal(t, APPLY_CREATED, result.Objects[0].Action, "First apply did not create the object.")
+			assert.Equal(t, "result", result.Objects[0].Object.GetName(), "Result does not carry the server's object.")
+			assert.Equal(t, "1 created, 0 updated, 0 unchanged", result.String(), "Unexpected summary of the first apply.")
+
+			result, err = client.ApplyResourcesWithResult(context.TODO(), interfaces, []*unstructured.Unstructured{testConfigMap("result", "default")}, ApplyOptions{})
+			if err != nil {
+				t.Fatalf("failed reapplying resources: %s", err)
+			}
+
+			assert.Equal(t, tc.second, result.Objects[0].Action, "Unexpected outcome of the second apply.")
+			assert.Equal(t, tc.summary, result.String(), "Unexpected summary of the second apply.")
+		})
+	}
+}
+
+func TestApplyResourcesErrorCause(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	testCases := []struct {
+		name     string
+		apiError error
+		code     int32
+	}{
+		{
+			"forbidden",
+			apierrors.NewForbidden(gvr.GroupResource(), "caused", fmt.Errorf("no access")),
+			403,
+		},
+		{
+			"invalid",
+			apierrors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, "caused", nil),
+			422,
+		},
+		{
+			"already exists",
+			apierrors.NewAlreadyExists(gvr.GroupResource(), "caused"),
+			409,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			dc.PrependReactor("create", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				return true, nil, tc.apiError
+			})
+
+			client := &K8sClients{DynamicClient: dc}
+			interfaces := []dynamic.ResourceInterface{dc.Resource(gvr).Namespace("default")}
+			objects := []*unstructured.Unstructured{testConfigMap("caused", "default")}
+
+			err := client.ApplyResources(context.TODO(), interfaces, objects)
+			if !assert.Error(t, err, "Expected apply to fail.") {
+				return
+			}
+
+			status, ok := errors.Cause(err).(apierrors.APIStatus)
+			if !assert.True(t, ok, "errors.Cause did not return an API status error.  Got: %T", errors.Cause(err)) {
+				return
+			}
+
+			assert.Equal(t, tc.code, status.Status().Code, "Underlying status code was lost.")
+			assert.Equal(t, tc.code, StatusCode(err), "StatusCode did not see through the wrapping.")
+		})
+	}
+}
+
+func TestApplyResourcesThreeWayMerge(t *testing.T) {
+	testCases := []struct {
+		name     string
+		first    map[string]interface{}
+		second   map[string]interface{}
+		expected map[string]interface{}
+		action   ApplyAction
+	}{
+		{
+			"field removed from manifest",
+			map[string]interface{}{"size": "large", "color": "red"},
+			map[string]interface{}{"size": "large"},
+			map[string]interface{}{"size": "large", "owner": "someone-else"},
+			APPLY_UPDATED,
+		},
+		{
+			"field changed",
+			map[string]interface{}{"size": "large"},
+			map[string]interface{}{"size": "small"},
+			map[string]interface{}{"size": "small", "owner": "someone-else"},
+			APPLY_UPDATED,
+		},
+		{
+			"nothing changed",
+			map[string]interface{}{"size": "large"},
+			map[string]interface{}{"size": "large"},
+			map[string]interface{}{"size": "large", "owner": "someone-else"},
+			APPLY_UNCHANGED,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Group: "utility-client.example.com", Version: "v1", Resource: "widgets"}
+
+	widget := func(spec map[string]interface{}) (obj *unstructured.Unstructured) {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "utility-client.example.com/v1",
+			"kind":       "Widget",
+			"metadata": map[string]interface{}{
+				"name":      "merged",
+				"namespace": "default",
+			},
+			"spec": runtime.DeepCopyJSON(spec),
+		}}
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+			// the fake doesn't manage resourceVersions, so play the part of the API server
+			writes := 0
+			dc.PrependReactor("patch", "widgets", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				handled, ret, err = k8stesting.ObjectReaction(dc.Tracker())(action)
+				if err != nil {
+					return handled, ret, err
+				}
+
+				writes++
+				patched := ret.(*unstructured.Unstructured)
+				patched.SetResourceVersion(fmt.Sprintf("%d", writes))
+
+				err = dc.Tracker().Update(gvr, patched, "default")
+
+				return handled, patched, err
+			})
+
+			client := &K8sClients{DynamicClient: dc}
+			ri := dc.Resource(gvr).Namespace("default")
+			ctx := context.TODO()
+			opts := ApplyOptions{ThreeWayMerge: true}
+
+			result, err := client.ApplyResourcesWithResult(ctx, []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{widget(tc.first)}, opts)
+			if err != nil {
+				t.Fatalf("failed first apply: %s", err)
+			}
+
+			assert.Equal(t, APPLY_CREATED, result.Objects[0].Action, "First apply did not create the object.")
+			assert.NotEmpty(t, result.Objects[0].Object.GetAnnotations()[corev1.LastAppliedConfigAnnotation], "Last applied configuration was not recorded.")
+
+			// another controller sets a field of its own, which must survive
+			_, err = ri.Patch(ctx, "merged", types.MergePatchType, []byte(`{"spec":{"owner":"someone-else"}}`), metav1.PatchOptions{})
+			if err != nil {
+				t.Fatalf("failed simulating another writer: %s", err)
+			}
+
+			result, err = client.ApplyResourcesWithResult(ctx, []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{widget(tc.second)}, opts)
+			if err != nil {
+				t.Fatalf("failed second apply: %s", err)
+			}
+
+			assert.Equal(t, tc.action, result.Objects[0].Action, "Unexpected outcome of the second apply.")
+
+			live, err := ri.Get(ctx, "merged", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed getting live object: %s", err)
+			}
+
+			spec, _, _ := unstructured.NestedMap(live.Object, "spec")
+			assert.Equal(t, tc.expected, spec, "Live object was not merged as expected.")
+		})
+	}
+}
+
+func TestApplyResourcesThreeWayMergeLive(t *testing.T) {
+	client, err := NewK8sClients()
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	ctx := context.TODO()
+	opts := ApplyOptions{ThreeWayMerge: true}
+
+	interfaces, objects, err := client.ResourcesAndObjectsFromFile("test_fixtures/configmap.yaml")
+	if err != nil {
+		t.Fatalf("failed to load yaml file: %s", err)
+	}
+
+	err = unstructured.SetNestedStringMap(objects[0].Object, map[string]string{"keep": "this", "remove": "this"}, "data")
+	if err != nil {
+		t.Fatalf("failed setting data: %s", err)
+	}
+
+	fmt.Printf("Creating resources in k8s.\n")
+	err = client.ApplyResourcesWithOptions(ctx, interfaces, objects, opts)
+	if err != nil {
+		t.Fatalf("failed to apply resources: %s", err)
+	}
+
+	defer func() {
+		fmt.Printf("Cleaning up resources in k8s.\n")
+		err = client.DeleteResources(ctx, interfaces, objects)
+		if err != nil {
+			t.Errorf("failed deleting resources: %s", err)
+		}
+	}()
+
+	unstructured.RemoveNestedField(objects[0].Object, "data", "remove")
+
+	err = client.ApplyResourcesWithOptions(ctx, interfaces, objects, opts)
+	if err != nil {
+		t.Fatalf("failed to reapply resources: %s", err)
+	}
+
+	live, err := interfaces[0].Get(ctx, objects[0].GetName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed getting live object: %s", err)
+	}
+
+	data, _, _ := unstructured.NestedStringMap(live.Object, "data")
+	assert.Equal(t, map[string]string{"keep": "this"}, data, "Field removed from the manifest was not removed from the live object.")
+}
+
+func TestApplyResourcesConcurrent(t *testing.T) {
+	testCases := []struct {
+		name        string
+		count       int
+		concurrency int
+		failing     int
+	}{
+		{
+			"sequential",
+			20,
+			1,
+			0,
+		},
+		{
+			"concurrent",
+			50,
+			8,
+			0,
+		},
+		{
+			"zero concurrency",
+			5,
+			0,
+			0,
+		},
+		{
+			"some failures",
+			20,
+			4,
+			3,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ConfigMapList"})
+			dc.PrependReactor("create", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				obj := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+				if strings.HasPrefix(obj.GetName(), "bad-") {
+					return true, nil, apierrors.NewForbidden(gvr.GroupResource(), obj.GetName(), fmt.Errorf("no access"))
+				}
+
+				return false, nil, nil
+			})
+
+			client := &K8sClients{DynamicClient: dc}
+			interfaces := make([]dynamic.ResourceInterface, 0)
+			objects := make([]*unstructured.Unstructured, 0)
+			for i := 0; i < tc.count; i++ {
+				name := fmt.Sprintf("concurrent-%d", i)
+				if i < tc.failing {
+					name = fmt.Sprintf("bad-%d", i)
+				}
+
+				interfaces = append(interfaces, dc.Resource(gvr).Namespace("default"))
+				objects = append(objects, testConfigMap(name, "default"))
+			}
+
+			err := client.ApplyResourcesConcurrent(context.TODO(), interfaces, objects, tc.concurrency)
+			if tc.failing > 0 {
+				agg, ok := err.(utilerrors.Aggregate)
+				if assert.True(t, ok, "Expected an aggregate error.  Got: %v", err) {
+					assert.Equal(t, tc.failing, len(agg.Errors()), "Unexpected number of errors.")
+				}
+			} else {
+				assert.NoError(t, err, "Unexpected error applying concurrently.")
+			}
+
+			list, err := dc.Resource(gvr).Namespace("default").List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed listing configmaps: %s", err)
+			}
+
+			assert.Equal(t, tc.count-tc.failing, len(list.Items), "Not every resource was applied.")
+		})
+	}
+}
+
+func TestApplyResourcesOnProgress(t *testing.T) {
+	testCases := []struct {
+		name     string
+		existing []string
+		names    []string
+		expected []string
+	}{
+		{
+			"all new",
+			[]string{},
+			[]string{"one", "two"},
+			[]string{
+				"0/2 one Applying",
+				"0/2 one Created",
+				"1/2 two Applying",
+				"1/2 two Created",
+			},
+		},
+		{
+			"some existing",
+			[]string{"two"},
+			[]string{"one", "two", "three"},
+			[]string{
+				"0/3 one Applying",
+				"0/3 one Created",
+				"1/3 two Applying",
+				"1/3 two Unchanged",
+				"2/3 three Applying",
+				"2/3 three Created",
+			},
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			existing := make([]runtime.Object, 0)
+			for _, name := range tc.existing {
+				existing = append(existing, testConfigMap(name, "default"))
+			}
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), existing...)
+			client := &K8sClients{DynamicClient: dc}
+
+			interfaces := make([]dynamic.ResourceInterface, 0)
+			objects := make([]*unstructured.Unstructured, 0)
+			for _, name := range tc.names {
+				interfaces = append(interfaces, dc.Resource(gvr).Namespace("default"))
+				objects = append(objects, testConfigMap(name, "default"))
+			}
+
+			actual := make([]string, 0)
+			opts := ApplyOptions{
+				OnProgress: func(index int, total int, obj *unstructured.Unstructured, action string) {
+					actual = append(actual, fmt.Sprintf("%d/%d %s %s", index, total, obj.GetName(), action))
+				},
+			}
+
+			err := client.ApplyResourcesWithOptions(context.TODO(), interfaces, objects, opts)
+			if err != nil {
+				t.Fatalf("failed applying resources: %s", err)
+			}
+
+			assert.Equal(t, tc.expected, actual, "Progress callbacks do not match expectations.")
+		})
+	}
+}
+
+func TestApplyObject(t *testing.T) {
+	testCases := []struct {
+		name      string
+		existing  []runtime.Object
+		object    *unstructured.Unstructured
+		namespace string
+		data      string
+	}{
+		{
+			"new",
+			[]runtime.Object{},
+			withData(testConfigMap("single", "apps"), "new"),
+			"apps",
+			"new",
+		},
+		{
+			"existing",
+			[]runtime.Object{withData(testConfigMap("single", "apps"), "old")},
+			withData(testConfigMap("single", "apps"), "new"),
+			"apps",
+			"new",
+		},
+		{
+			"no namespace",
+			[]runtime.Object{},
+			withData(testConfigMap("single", ""), "new"),
+			"default",
+			"new",
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), tc.existing...)
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			applied, err := client.ApplyObject(context.TODO(), tc.object)
+			if err != nil {
+				t.Fatalf("failed applying object: %s", err)
+			}
+
+			assert.Equal(t, tc.namespace, applied.GetNamespace(), "Applied object namespace does not match expectations.")
+
+			live, err := dc.Resource(gvr).Namespace(tc.namespace).Get(context.TODO(), "single", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed getting applied object: %s", err)
+			}
+
+			data, _, _ := unstructured.NestedString(live.Object, "data", "foo")
+			assert.Equal(t, tc.data, data, "Live object data does not match expectations.")
+		})
+	}
+}
+
+// withData  Sets data.foo on a test ConfigMap.
+func withData(obj *unstructured.Unstructured, value string) *unstructured.Unstructured {
+	_ = unstructured.SetNestedField(obj.Object, value, "data", "foo")
+
+	return obj
+}
+
+func TestApplyTypedObject(t *testing.T) {
+	replicas := int32(2)
+
+	testCases := []struct {
+		name   string
+		object runtime.Object
+		gvr    schema.GroupVersionResource
+	}{
+		{
+			"deployment without type meta",
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "typed", Namespace: "default"},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			},
+			schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+		},
+		{
+			"configmap with type meta",
+			&corev1.ConfigMap{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+				ObjectMeta: metav1.ObjectMeta{Name: "typed", Namespace: "default"},
+				Data:       map[string]string{"foo": "bar"},
+			},
+			schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			err := client.ApplyTypedObject(context.TODO(), tc.object)
+			if err != nil {
+				t.Fatalf("failed applying typed object: %s", err)
+			}
+
+			_, err = dc.Resource(tc.gvr).Namespace("default").Get(context.TODO(), "typed", metav1.GetOptions{})
+			assert.NoError(t, err, "Typed object was not applied.")
+		})
+	}
+}
+
+func TestApplyResourcesContinueOnError(t *testing.T) {
+	testCases := []struct {
+		name            string
+		continueOnError bool
+		failures        int
+		applied         []string
+	}{
+		{
+			"fail fast",
+			false,
+			1,
+			[]string{"good-one"},
+		},
+		{
+			"continue on error",
+			true,
+			2,
+			[]string{"good-one", "good-two"},
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			dc.PrependReactor("create", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				obj := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+				if strings.HasPrefix(obj.GetName(), "bad-") {
+					return true, nil, apierrors.NewForbidden(gvr.GroupResource(), obj.GetName(), errors.New("not allowed"))
+				}
+
+				return false, nil, nil
+			})
+
+			client := &K8sClients{DynamicClient: dc}
+
+			names := []string{"good-one", "bad-one", "good-two", "bad-two"}
+			interfaces := make([]dynamic.ResourceInterface, 0)
+			objects := make([]*unstructured.Unstructured, 0)
+			for _, name := range names {
+				interfaces = append(interfaces, dc.Resource(gvr).Namespace("default"))
+				objects = append(objects, testConfigMap(name, "default"))
+			}
+
+			result, err := client.ApplyResourcesWithResult(context.TODO(), interfaces, objects, ApplyOptions{ContinueOnError: tc.continueOnError})
+			if !assert.Error(t, err, "Expected an error applying forbidden objects.") {
+				return
+			}
+
+			if tc.continueOnError {
+				var aggregate utilerrors.Aggregate
+				if assert.True(t, errors.As(err, &aggregate), "Expected an aggregate error.  Got: %s", err) {
+					assert.Equal(t, tc.failures, len(aggregate.Errors()), "Wrong number of errors reported.")
+				}
+
+				assert.Contains(t, err.Error(), "bad-one", "First failure not reported.")
+				assert.Contains(t, err.Error(), "bad-two", "Second failure not reported.")
+			}
+
+			applied := make([]string, 0)
+			for _, o := range result.Objects {
+				applied = append(applied, o.Object.GetName())
+			}
+
+			assert.Equal(t, tc.applied, applied, "Applied objects do not match expectations.")
+		})
+	}
+}
+
+func TestApplyResourcesOnlyNamespaces(t *testing.T) {
+	testCases := []struct {
+		name       string
+		namespaces []string
+		applied    []string
+	}{
+		{
+			"every namespace",
+			nil,
+			[]string{"team-a/utility-client-config", "team-b/utility-client-config", "team-c/utility-client-config", "utility-client-reader"},
+		},
+		{
+			"one namespace",
+			[]string{"team-a"},
+			[]string{"team-a/utility-client-config", "utility-client-reader"},
+		},
+		{
+			"several namespaces",
+			[]string{"team-a", "team-c"},
+			[]string{"team-a/utility-client-config", "team-c/utility-client-config", "utility-client-reader"},
+		},
+		{
+			"no matching namespace",
+			[]string{"team-z"},
+			[]string{"utility-client-reader"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile("test_fixtures/multi-namespace.yaml")
+			if err != nil {
+				t.Fatalf("failed loading multi-namespace manifest: %s", err)
+			}
+
+			result, err := client.ApplyResourcesWithResult(context.TODO(), interfaces, objects, ApplyOptions{OnlyNamespaces: tc.namespaces})
+			if err != nil {
+				t.Fatalf("failed applying resources: %s", err)
+			}
+
+			applied := make([]string, 0)
+			for _, o := range result.Objects {
+				if o.Object.GetNamespace() == "" {
+					applied = append(applied, o.Object.GetName())
+					continue
+				}
+
+				applied = append(applied, fmt.Sprintf("%s/%s", o.Object.GetNamespace(), o.Object.GetName()))
+			}
+
+			assert.Equal(t, tc.applied, applied, "Unexpected objects applied.")
+		})
+	}
+}
+
+func TestApplyFromFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+		errors   bool
+	}{
+		{
+			"configmap",
+			"test_fixtures/configmap.yaml",
+			false,
+		},
+		{
+			"missing file",
+			"test_fixtures/no-such-file.yaml",
+			true,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			err := client.ApplyFromFile(context.TODO(), tc.fileName, ApplyOptions{})
+			if tc.errors {
+				assert.Error(t, err, "Expected an error applying from a missing file.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed applying from file: %s", err)
+			}
+
+			_, err = dc.Resource(gvr).Namespace("default").Get(context.TODO(), "utility-client-test", metav1.GetOptions{})
+			assert.NoError(t, err, "ConfigMap from file was not applied.")
+		})
+	}
+}
+
+func TestApplyResourcesSkipUnchanged(t *testing.T) {
+	testCases := []struct {
+		name          string
+		skipUnchanged bool
+		value         string
+		updates       int
+		action        ApplyAction
+	}{
+		{
+			"identical object skipped",
+			true,
+			"bar",
+			0,
+			APPLY_UNCHANGED,
+		},
+		{
+			"changed object updated",
+			true,
+			"baz",
+			1,
+			APPLY_UPDATED,
+		},
+		{
+			"identical object updated without the option",
+			false,
+			"bar",
+			1,
+			APPLY_UPDATED,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+			// the fake doesn't bump resourceVersions, so do it ourselves, and count the updates while we're at it
+			updates := 0
+			version := 0
+			dc.PrependReactor("*", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				// create and update actions have the same methods, so tell them apart by verb
+				switch action.GetVerb() {
+				case "update":
+					updates++
+				case "create":
+				default:
+					return false, nil, nil
+				}
+
+				version++
+				action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured).SetResourceVersion(fmt.Sprintf("%d", version))
+
+				return false, nil, nil
+			})
+
+			client := &K8sClients{DynamicClient: dc}
+			interfaces := []dynamic.ResourceInterface{dc.Resource(gvr).Namespace("default")}
+
+			err := client.ApplyResources(context.TODO(), interfaces, []*unstructured.Unstructured{testConfigMap("reapplied", "default")})
+			if err != nil {
+				t.Fatalf("failed applying resources: %s", err)
+			}
+
+			objects := []*unstructured.Unstructured{withData(testConfigMap("reapplied", "default"), tc.value)}
+
+			result, err := client.ApplyResourcesWithResult(context.TODO(), interfaces, objects, ApplyOptions{SkipUnchanged: tc.skipUnchanged})
+			if err != nil {
+				t.Fatalf("failed re-applying resources: %s", err)
+			}
+
+			assert.Equal(t, tc.updates, updates, "Unexpected number of Update calls.")
+			if assert.Len(t, result.Objects, 1, "Unexpected number of results.") {
+				assert.Equal(t, tc.action, result.Objects[0].Action, "Unexpected action reported.")
+			}
+		})
+	}
+}
@@ -10,60 +10,118 @@ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLI
 package k8s_utility_client
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
+	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"io"
+	"io/fs"
 	"k8s.io/apimachinery/pkg/api/meta"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
 	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
-	"log"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
 )
 
 // IN_POD_NAMESPACE_FILE  If this file exists, odds are you're running in a k8s pod.  From here we can determine both that we're in k8s, and what our current namespace is
 const IN_POD_NAMESPACE_FILE = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 
+// DEFAULT_NAMESPACE  The namespace used when nothing else says which one to use.
+const DEFAULT_NAMESPACE = "default"
+
 type K8sClients struct {
 	InCluster     bool
 	ClientSet     *kubernetes.Clientset
 	DynamicClient dynamic.Interface
 	K8SConfig     *rest.Config
 	Namespace     string
+	RESTMapper    meta.RESTMapper
+	// Logger  Where informational output, such as what's being deleted, goes.  Discarded unless you set it.
+	Logger logr.Logger
+	// discoveryClient  Caches what the API server supports.  See Discovery().
+	discoveryClient discovery.CachedDiscoveryInterface
+	// warnings  Collects warnings from the API server, unless a rest.WarningHandler was given.  See Warnings().
+	warnings *warningCollector
+}
+
+// SetLogger  Sends the client's informational output to the given logger.  Use something like funcr.New() or stdr.New() to see it, or logr.Discard() to silence it again.
+func (k *K8sClients) SetLogger(logger logr.Logger) {
+	k.Logger = logger
+}
+
+// CurrentNamespace  Returns the client's namespace, or DEFAULT_NAMESPACE if it's empty, as it is when the kubeconfig context doesn't specify one.  Always a usable value.
+func (k *K8sClients) CurrentNamespace() string {
+	if k.Namespace == "" {
+		return DEFAULT_NAMESPACE
+	}
+
+	return k.Namespace
+}
+
+// log  Returns the client's logger, falling back to discarding everything if it was never set, as with a K8sClients built by hand.
+func (k *K8sClients) log() logr.Logger {
+	if k.Logger.GetSink() == nil {
+		return logr.Discard()
+	}
+
+	return k.Logger
 }
 
 // NewK8sClients  Creates both standard k8s Clientsets and a Dynamic Clientset for Unstructured resources.  Autodetcts whether it's running in a cluster, or outside.  Looks for default config files in the usual places and automagically does the right thing.
 func NewK8sClients() (clients *K8sClients, err error) {
+	return NewK8sClientsWithOptions(ClientOptions{})
+}
+
+// NewK8sClientsWithOptions  Creates the same clients as NewK8sClients, but tunes the rest.Config according to the ClientOptions before the clientsets are built.
+func NewK8sClientsWithOptions(opts ClientOptions) (clients *K8sClients, err error) {
 	clients = &K8sClients{
 		InCluster:     false,
 		ClientSet:     nil,
 		DynamicClient: nil,
 		K8SConfig:     nil,
 		Namespace:     "",
+		Logger:        logr.Discard(),
 	}
 	// Initialize K8S Client
-	// detect whether we're running in a k8s cluster or not.  If we're in a cluster, IN_POD_NAMESPACE_FILE will exist
-	if _, err := os.Stat(IN_POD_NAMESPACE_FILE); !os.IsNotExist(err) {
+	// detect whether we're running in a k8s cluster or not, unless we've been told
+	inCluster, err := opts.inCluster()
+	if err != nil {
+		return clients, err
+	}
+
+	if inCluster {
 		clients.InCluster = true
 
-		// read the file.  The contents are our namespace
+		// read the file.  The contents are our namespace.  If we've been forced in-cluster without it, fall back on the default namespace
+		clients.Namespace = DEFAULT_NAMESPACE
 		nsb, err := os.ReadFile(IN_POD_NAMESPACE_FILE)
-		if err != nil {
-			log.Fatalf("failed reading in-pod namespace file: %s", IN_POD_NAMESPACE_FILE)
+		if err != nil && !os.IsNotExist(err) {
+			err = errors.Wrapf(err, "failed reading in-pod namespace file: %s", IN_POD_NAMESPACE_FILE)
+			return clients, err
 		}
 
 		// set the namespace
-		clients.Namespace = string(nsb)
+		if err == nil {
+			clients.Namespace = namespaceFromBytes(nsb)
+		}
 
 		// create the client config for in-cluster work
 		cc, err := rest.InClusterConfig()
@@ -74,198 +132,601 @@ func NewK8sClients() (clients *K8sClients, err error) {
 
 		clients.K8SConfig = cc
 
-	} else { // We're not in a cluster, so look on the filesystem for the default k8s config file
-		configFile := fmt.Sprintf("%s/.kube/config", homedir.HomeDir())
+	} else { // We're not in a cluster, so look on the filesystem for k8s config files.  The default loading rules honor $KUBECONFIG, merging every file listed there, and fall back to ~/.kube/config
+		loadingRules, err := defaultLoadingRules()
+		if err != nil {
+			return clients, err
+		}
+
+		err = clients.loadKubeconfig(loadingRules, &clientcmd.ConfigOverrides{})
+		if err != nil {
+			return clients, err
+		}
+	}
 
-		// read the file
+	err = opts.apply(clients.K8SConfig)
+	if err != nil {
+		return clients, err
+	}
+
+	if opts.Metrics {
+		err = EnableMetrics(prometheus.DefaultRegisterer)
+		if err != nil {
+			return clients, err
+		}
+	}
+
+	if opts.DiscoveryCacheDir != "" {
+		clients.discoveryClient, err = diskCachedDiscovery(clients.K8SConfig, opts.DiscoveryCacheDir, opts.DiscoveryCacheTTL)
+		if err != nil {
+			return clients, err
+		}
+	}
+
+	err = clients.createClients()
+
+	return clients, err
+
+}
+
+// NewK8sClientsWithConfigPath  Creates the same clients as NewK8sClients, but loads the kubeconfig file at the given path instead of autodetecting.  Handy for pointing at a cluster whose config lives somewhere unusual, such as a kind cluster created by a test harness.
+func NewK8sClientsWithConfigPath(path string) (clients *K8sClients, err error) {
+	clients = &K8sClients{
+		InCluster:     false,
+		ClientSet:     nil,
+		DynamicClient: nil,
+		K8SConfig:     nil,
+		Namespace:     "",
+		Logger:        logr.Discard(),
+	}
+
+	loadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: path}
+
+	err = clients.loadKubeconfig(loadingRules, &clientcmd.ConfigOverrides{})
+	if err != nil {
+		return clients, err
+	}
+
+	err = clients.createClients()
+
+	return clients, err
+}
+
+// NewK8sClientsWithContext  Creates the same clients as NewK8sClients, but uses the named kubeconfig context instead of the current-context.  Lets users with a multi-cluster kubeconfig target a specific cluster without editing any files.  Namespace is taken from the selected context.
+func NewK8sClientsWithContext(contextName string) (clients *K8sClients, err error) {
+	clients = &K8sClients{
+		InCluster:     false,
+		ClientSet:     nil,
+		DynamicClient: nil,
+		K8SConfig:     nil,
+		Namespace:     "",
+		Logger:        logr.Discard(),
+	}
+
+	loadingRules, err := defaultLoadingRules()
+	if err != nil {
+		return clients, err
+	}
+
+	err = clients.loadKubeconfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: contextName})
+	if err != nil {
+		return clients, err
+	}
+
+	err = clients.createClients()
+
+	return clients, err
+}
+
+// NewK8sClientsFromKubeconfig  Creates the same clients as NewK8sClients from the raw contents of a kubeconfig file.  Useful in CI, where the kubeconfig often lives in an environment variable or secret, and writing it to a temp file would leave credentials lying around on disk.
+func NewK8sClientsFromKubeconfig(data []byte) (clients *K8sClients, err error) {
+	clients = &K8sClients{
+		InCluster:     false,
+		ClientSet:     nil,
+		DynamicClient: nil,
+		K8SConfig:     nil,
+		Namespace:     "",
+		Logger:        logr.Discard(),
+	}
+
+	config, err := clientcmd.Load(data)
+	if err != nil {
+		err = errors.Wrapf(err, "failed parsing kubeconfig")
+		return clients, err
+	}
+
+	err = clients.useKubeconfig(config, &clientcmd.ConfigOverrides{})
+	if err != nil {
+		return clients, err
+	}
+
+	err = clients.createClients()
+
+	return clients, err
+}
+
+// NewK8sClientsWithToken  Creates the same clients as NewK8sClients straight from an API server URL, its CA certificate (PEM encoded), and a bearer token, such as a service account token handed over by an external auth system.  No kubeconfig needed.  The namespace is "default".
+func NewK8sClientsWithToken(host string, caData []byte, token string) (clients *K8sClients, err error) {
+	clients = &K8sClients{
+		InCluster:     false,
+		ClientSet:     nil,
+		DynamicClient: nil,
+		K8SConfig:     nil,
+		Namespace:     DEFAULT_NAMESPACE,
+		Logger:        logr.Discard(),
+	}
+
+	clients.K8SConfig = &rest.Config{
+		Host:            host,
+		BearerToken:     token,
+		TLSClientConfig: rest.TLSClientConfig{CAData: caData},
+	}
+
+	err = clients.createClients()
+
+	return clients, err
+}
+
+// defaultLoadingRules  Returns the default kubeconfig loading rules, which honor $KUBECONFIG and fall back to ~/.kube/config.  Errors if none of the files exist.
+func defaultLoadingRules() (loadingRules *clientcmd.ClientConfigLoadingRules, err error) {
+	loadingRules = clientcmd.NewDefaultClientConfigLoadingRules()
+
+	configFiles := make([]string, 0)
+	for _, configFile := range loadingRules.GetLoadingPrecedence() {
 		if _, err := os.Stat(configFile); !os.IsNotExist(err) {
-			config, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
-			if err != nil {
-				log.Fatalf("failed loading kubeconfig file: %s", configFile)
-			}
+			configFiles = append(configFiles, configFile)
+		}
+	}
 
-			clients.Namespace = "default"
+	// error out if the k8s config doesn't exist
+	if len(configFiles) == 0 {
+		err = errors.New(fmt.Sprintf("k8s config file(s) %s do not exist.  Cannot continue", strings.Join(loadingRules.GetLoadingPrecedence(), ", ")))
+		return loadingRules, err
+	}
 
-			if config.CurrentContext != "" {
-				if config.Contexts[config.CurrentContext] != nil {
-					clients.Namespace = config.Contexts[config.CurrentContext].Namespace
-				}
-			}
+	return loadingRules, err
+}
 
-			// create a config from the file
-			cc, err := clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}).ClientConfig()
-			if err != nil {
-				err = errors.Wrapf(err, "failed creating default kubernetes client config")
-				return clients, err
-			}
+// loadKubeconfig  Loads kubeconfig file(s) according to the loading rules, and sets K8SConfig and Namespace from the selected context.
+func (k *K8sClients) loadKubeconfig(loadingRules *clientcmd.ClientConfigLoadingRules, overrides *clientcmd.ConfigOverrides) (err error) {
+	// read the file(s)
+	config, err := loadingRules.Load()
+	if err != nil {
+		err = errors.Wrapf(err, "failed loading kubeconfig file(s): %s", strings.Join(loadingRules.GetLoadingPrecedence(), ", "))
+		return err
+	}
 
-			clients.K8SConfig = cc
-		} else { // error out if the k8s config doesn't exist
-			err = errors.New(fmt.Sprintf("k8s config file %s does not exist.  Cannot continue", configFile))
-			return clients, err
+	err = k.useKubeconfig(config, overrides)
+
+	return err
+}
+
+// useKubeconfig  Sets K8SConfig and Namespace from the selected context of an already loaded kubeconfig.
+func (k *K8sClients) useKubeconfig(config *clientcmdapi.Config, overrides *clientcmd.ConfigOverrides) (err error) {
+	k.Namespace = DEFAULT_NAMESPACE
+
+	// an overridden context wins over the current-context in the file
+	contextName := config.CurrentContext
+	if overrides.CurrentContext != "" {
+		contextName = overrides.CurrentContext
+	}
+
+	if contextName != "" {
+		if config.Contexts[contextName] != nil {
+			k.Namespace = config.Contexts[contextName].Namespace
 		}
 	}
 
+	// create a config from the file(s)
+	cc, err := clientcmd.NewDefaultClientConfig(*config, overrides).ClientConfig()
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating default kubernetes client config")
+		return err
+	}
+
+	k.K8SConfig = cc
+
+	return err
+}
+
+// createClients  Creates the standard and dynamic clientsets from K8SConfig.
+func (k *K8sClients) createClients() (err error) {
 	// bail if we still don't have a client config
-	if clients.K8SConfig == nil {
-		err := errors.New("Failed creating k8s client config.  Cannot proceed with tests.")
-		log.Fatal(err)
+	if k.K8SConfig == nil {
+		err = errors.New("Failed creating k8s client config.  Cannot proceed with tests.")
+		return err
+	}
+
+	// identify ourselves in the audit logs, however the config was made, unless the caller already has
+	if k.K8SConfig.UserAgent == "" {
+		k.K8SConfig.UserAgent = DEFAULT_USER_AGENT
+	}
+
+	// keep warnings for Warnings(), rather than just logging them, unless someone else wants them
+	if k.K8SConfig.WarningHandler == nil {
+		k.warnings = &warningCollector{clients: k}
+		k.K8SConfig.WarningHandler = k.warnings
 	}
 
 	// create a k8s clientset
-	cs, err := kubernetes.NewForConfig(clients.K8SConfig)
+	cs, err := kubernetes.NewForConfig(k.K8SConfig)
 	if err != nil {
-		log.Fatalf("failed creating k8s clientset: %s", err)
+		err = errors.Wrapf(err, "failed creating k8s clientset")
+		return err
 	}
 
 	// set the global var
-	clients.ClientSet = cs
+	k.ClientSet = cs
 
 	// create a dynamic clientset
-	dc, err := dynamic.NewForConfig(clients.K8SConfig)
+	dc, err := dynamic.NewForConfig(k.K8SConfig)
 	if err != nil {
-		log.Fatalf("failed k8s dynamic client: %s", err)
+		err = errors.Wrapf(err, "failed creating k8s dynamic client")
+		return err
 	}
 
 	// set the global var
-	clients.DynamicClient = dc
+	k.DynamicClient = dc
+
+	// resolving kinds to resources needs discovery.  The deferred mapper doesn't hit the API server until it's first asked for a mapping, and caches what it learns
+	k.RESTMapper = restmapper.NewDeferredDiscoveryRESTMapper(k.cachedDiscovery())
 
 	// Bail if we don't have k8s clients
-	if clients.ClientSet == nil {
-		err := errors.New("Failed creating k8s clientset.  Cannot proceed with tests.")
-		return clients, err
+	if k.ClientSet == nil {
+		err = errors.New("Failed creating k8s clientset.  Cannot proceed with tests.")
+		return err
 	}
 
-	if clients.DynamicClient == nil {
-		err := errors.New("Failed creating k8s dynamic client.  Cannot proceed with tests.")
-		return clients, err
+	if k.DynamicClient == nil {
+		err = errors.New("Failed creating k8s dynamic client.  Cannot proceed with tests.")
+		return err
 	}
 
-	return clients, err
+	return err
+}
+
+// namespaceFromBytes  Converts the contents of the in-pod namespace file into a namespace name.  The file frequently carries a trailing newline, which the API server will not accept as part of a namespace.
+func namespaceFromBytes(nsb []byte) (namespace string) {
+	namespace = strings.TrimSpace(string(nsb))
 
+	return namespace
 }
 
+// ResourcesAndObjectsFromFile  Reads a k8s yaml file and converts it into Unstructured interfaces that can be applied to the cluster.  See ResourcesAndObjectsFromReader.
 func (k *K8sClients) ResourcesAndObjectsFromFile(fileName string) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
 
-	b, err := os.ReadFile(fileName)
+	f, err := os.Open(fileName)
 	if err != nil {
 		err = errors.Wrapf(err, "failed reading file %s", fileName)
 		return interfaces, objects, err
 	}
 
-	return k.ResourcesAndObjectsFromBytes(b)
+	defer f.Close()
+
+	return k.ResourcesAndObjectsFromReader(f)
 }
 
-// ResourcesAnd ObjectsFromYaml Reads k8s yaml files and converts them into Unstructured interfaces that can be applied to the cluster similar to `kubectl apply -f`
-func (k *K8sClients) ResourcesAndObjectsFromBytes(yamlBytes []byte) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
+// ResourcesAndObjectsFromDirectory  Reads every .yaml, .yml, and .json file in a directory, like `kubectl apply -f ./manifests/`, and converts them into Unstructured interfaces that can be applied to the cluster.  Subdirectories are descended into if recursive is true.  Files are read in sorted path order, so the results are deterministic.  Anything else in the directory is skipped.
+func (k *K8sClients) ResourcesAndObjectsFromDirectory(dir string, recursive bool) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
 	interfaces = make([]dynamic.ResourceInterface, 0)
 	objects = make([]*unstructured.Unstructured, 0)
 
-	decoder := yamlutil.NewYAMLOrJSONDecoder(bytes.NewReader(yamlBytes), 100)
-	for {
-		var rawObj runtime.RawExtension
-		if err = decoder.Decode(&rawObj); err != nil {
-			break
-		}
+	fileNames := make([]string, 0)
 
-		obj, gvk, err := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme).Decode(rawObj.Raw, nil, nil)
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			err = errors.Wrapf(err, "failed decoding resource file")
-			return interfaces, objects, err
+			return err
 		}
 
-		unstructuredMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
-		if err != nil {
-			err = errors.Wrapf(err, "failed converting object unstructured")
-			return interfaces, objects, err
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+			fileNames = append(fileNames, path)
 		}
 
-		unstructuredObj := &unstructured.Unstructured{Object: unstructuredMap}
+		return nil
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed walking directory %s", dir)
+		return interfaces, objects, err
+	}
+
+	sort.Strings(fileNames)
 
-		gr, err := restmapper.GetAPIGroupResources(k.ClientSet.Discovery())
+	for _, fileName := range fileNames {
+		fileInterfaces, fileObjects, err := k.ResourcesAndObjectsFromFile(fileName)
 		if err != nil {
-			err = errors.Wrapf(err, "failed getting api group resources")
+			err = errors.Wrapf(err, "failed loading %s", fileName)
 			return interfaces, objects, err
 		}
 
-		mapper := restmapper.NewDiscoveryRESTMapper(gr)
-		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		interfaces = append(interfaces, fileInterfaces...)
+		objects = append(objects, fileObjects...)
+	}
+
+	return interfaces, objects, err
+}
+
+// ResourcesAndObjectsFromURL  Fetches a manifest from a URL, such as a release asset or a raw file in a git repo, and converts it into Unstructured interfaces that can be applied to the cluster.  The request is bound to ctx, so it can be cancelled or given a deadline.  Non-2xx responses are errors.
+func (k *K8sClients) ResourcesAndObjectsFromURL(ctx context.Context, url string) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating request for %s", url)
+		return interfaces, objects, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		err = errors.Wrapf(err, "failed fetching %s", url)
+		return interfaces, objects, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = errors.New(fmt.Sprintf("failed fetching %s: %d %s", url, resp.StatusCode, http.StatusText(resp.StatusCode)))
+		return interfaces, objects, err
+	}
+
+	return k.ResourcesAndObjectsFromReader(resp.Body)
+}
+
+// ResourcesAndObjectsFromBytes  Converts k8s yaml into Unstructured interfaces that can be applied to the cluster.  See ResourcesAndObjectsFromReader.
+func (k *K8sClients) ResourcesAndObjectsFromBytes(yamlBytes []byte) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
+	return k.ResourcesAndObjectsFromReader(bytes.NewReader(yamlBytes))
+}
+
+// ResourcesAndObjectsFromReader Reads a stream of k8s yaml or json documents and converts them into Unstructured interfaces that can be applied to the cluster similar to `kubectl apply -f`.  Documents are decoded one at a time as they're read, so the stream needn't fit in memory all at once.  Custom resources can be loaded from the same stream as the CRDs that define them, before the CRDs are applied.  Gzipped streams, such as a .yaml.gz file, are detected and decompressed automatically.
+func (k *K8sClients) ResourcesAndObjectsFromReader(r io.Reader) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
+	return k.resourcesAndObjectsFromReader(r, "")
+}
+
+// ResourcesAndObjectsFromBytesInNamespace  Converts k8s yaml into Unstructured interfaces like ResourcesAndObjectsFromBytes, but puts every namespaced object into the given namespace, regardless of what the yaml says.  Cluster-scoped objects are left alone.
+func (k *K8sClients) ResourcesAndObjectsFromBytesInNamespace(yamlBytes []byte, namespace string) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
+	return k.resourcesAndObjectsFromReader(bytes.NewReader(yamlBytes), namespace)
+}
+
+// gzipMagic  The first two bytes of any gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress  Wraps r in a gzip reader if the stream starts with gzipMagic.  Otherwise the stream is returned intact.  We only peek at the first bytes, so nothing is lost.
+func decompress(r io.Reader) (decompressed io.Reader, err error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		err = errors.Wrapf(err, "failed reading manifest")
+		return br, err
+	}
+
+	if !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
+
+	decompressed, err = gzip.NewReader(br)
+	if err != nil {
+		err = errors.Wrapf(err, "failed decompressing gzipped manifest")
+		return br, err
+	}
+
+	return decompressed, nil
+}
+
+// trimJSONPrefix  Drops the whitespace in front of a JSON stream.  The decoder only sniffs the first 100 bytes to choose between JSON and YAML, so a stream padded with more whitespace than that is read as YAML, and every object after the first is quietly lost.  YAML streams are returned intact, since leading spaces can be indentation.
+func trimJSONPrefix(r io.Reader) (trimmed io.Reader, err error) {
+	br := bufio.NewReader(r)
+	whitespace := make([]byte, 0)
+
+	for {
+		b, err := br.ReadByte()
 		if err != nil {
-			err = errors.Wrapf(err, "failed creating rest mapping")
-			return interfaces, objects, err
+			if err == io.EOF {
+				return bytes.NewReader(whitespace), nil
+			}
+
+			err = errors.Wrapf(err, "failed reading manifest")
+			return br, err
 		}
 
-		var dri dynamic.ResourceInterface
-		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
-			if unstructuredObj.GetNamespace() == "" {
-				unstructuredObj.SetNamespace("default")
-			}
-			dri = k.DynamicClient.Resource(mapping.Resource).Namespace(unstructuredObj.GetNamespace())
-		} else {
-			dri = k.DynamicClient.Resource(mapping.Resource)
+		if unicode.IsSpace(rune(b)) {
+			whitespace = append(whitespace, b)
+			continue
 		}
 
-		if dri != nil && unstructuredObj != nil {
-			interfaces = append(interfaces, dri)
-			objects = append(objects, unstructuredObj)
+		err = br.UnreadByte()
+		if err != nil {
+			err = errors.Wrapf(err, "failed reading manifest")
+			return br, err
 		}
+
+		if b == '{' {
+			return br, nil
+		}
+
+		return io.MultiReader(bytes.NewReader(whitespace), br), nil
 	}
+}
 
-	if err != io.EOF {
-		err = errors.Wrapf(err, "encountered something weird when parsing yaml")
+// resourcesAndObjectsFromReader  Does the actual work for the ResourcesAndObjectsFrom* functions.  If namespace is non-empty, it overrides the namespace of every namespaced object.
+func (k *K8sClients) resourcesAndObjectsFromReader(r io.Reader, namespace string) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
+	interfaces = make([]dynamic.ResourceInterface, 0)
+	objects = make([]*unstructured.Unstructured, 0)
+
+	decoded, err := objectsFromReader(r)
+	if err != nil {
 		return interfaces, objects, err
 	}
 
-	// reset err to nil, since io.EOF is actually expected
-	err = nil
+	interfaces, objects, err = k.resourcesForObjects(decoded, namespace, "")
 
 	return interfaces, objects, err
 }
 
-// ApplyResources  Takes a list of Unstructured interfaces and 'objects' and applies them to the cluster.  ApplyResources will try to Get the resources first, and if they already exist, it will Update them.
-func (k *K8sClients) ApplyResources(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured) (err error) {
-	for i, ri := range interfaces {
-		obj := objects[i]
-
-		// Try to get the resource from k8s.  If it exists, we'll have to update, and cope with the optimistic lock
-		res, getErr := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
-		if getErr == nil {
-			rv := res.GetResourceVersion()
-			obj.SetResourceVersion(rv)
-
-			_, err := ri.Update(ctx, obj, metav1.UpdateOptions{})
-			if err != nil {
-				err = errors.Wrapf(err, "failed updating %s kind %s", obj.GetName(), obj.GetKind())
-				return err
-			}
+// LoadedResource  An object loaded from a manifest, along with what its kind resolved to.
+type LoadedResource struct {
+	// Object  The object as loaded, with its namespace filled in.
+	Object *unstructured.Unstructured
+	// Interface  Where the object can be created, fetched, or deleted.
+	Interface dynamic.ResourceInterface
+	// GVK  The object's group, version, and kind, as written in the manifest.
+	GVK schema.GroupVersionKind
+	// Mapping  What the kind resolved to, including the resource name and whether it's namespaced.
+	Mapping *meta.RESTMapping
+}
 
-		} else {
-			_, err := ri.Create(ctx, obj, metav1.CreateOptions{})
-			if err != nil {
-				err = errors.Wrapf(err, "failed creating %s kind %s", obj.GetName(), obj.GetKind())
-				return err
-			}
+// LoadedResourcesFromBytes  Loads a manifest just like ResourcesAndObjectsFromBytes, but pairs each object with its GVK and REST mapping, rather than handing back parallel slices.  Handy when building your own logic on top, such as deciding what to do by kind or scope.
+func (k *K8sClients) LoadedResourcesFromBytes(yamlBytes []byte) (resources []LoadedResource, err error) {
+	decoded, err := objectsFromReader(bytes.NewReader(yamlBytes))
+	if err != nil {
+		return resources, err
+	}
+
+	return k.loadResources(decoded, "", "")
+}
+
+// resourcesForObjects  Resolves each decoded object against the cluster, pairing it with its dynamic.ResourceInterface.  See loadResources() for the namespaces.
+func (k *K8sClients) resourcesForObjects(decoded []*unstructured.Unstructured, namespace string, defaultNamespace string) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
+	interfaces = make([]dynamic.ResourceInterface, 0)
+	objects = make([]*unstructured.Unstructured, 0)
+
+	resources, err := k.loadResources(decoded, namespace, defaultNamespace)
+	if err != nil {
+		return interfaces, objects, err
+	}
+
+	for _, resource := range resources {
+		interfaces = append(interfaces, resource.Interface)
+		objects = append(objects, resource.Object)
+	}
+
+	return interfaces, objects, err
+}
+
+// loadResources  Resolves each decoded object against the cluster.  If namespace is non-empty, it overrides the namespace of every namespaced object.  If defaultNamespace is non-empty, namespaced objects without a namespace get it, rather than "default".
+func (k *K8sClients) loadResources(decoded []*unstructured.Unstructured, namespace string, defaultNamespace string) (resources []LoadedResource, err error) {
+	resources = make([]LoadedResource, 0)
+
+	mapper, err := k.Mapper()
+	if err != nil {
+		return resources, err
+	}
+
+	// The cluster can't know about kinds defined by CRDs in this same stream until they're applied, so fall back on the CRDs themselves to resolve those
+	crdMapper := crdRESTMapper(decoded)
+
+	for _, unstructuredObj := range decoded {
+		gvk := unstructuredObj.GroupVersionKind()
+
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if meta.IsNoMatchError(err) {
+			mapping, err = crdMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		}
+
+		if err != nil {
+			err = errors.Wrapf(err, "failed creating rest mapping")
+			return resources, err
+		}
+
+		if defaultNamespace != "" && mapping.Scope.Name() == meta.RESTScopeNameNamespace && unstructuredObj.GetNamespace() == "" {
+			unstructuredObj.SetNamespace(defaultNamespace)
+		}
+
+		dri := k.objectInterface(mapping, unstructuredObj, namespace)
+
+		if dri != nil && unstructuredObj != nil {
+			resources = append(resources, LoadedResource{
+				Object:    unstructuredObj,
+				Interface: dri,
+				GVK:       gvk,
+				Mapping:   mapping,
+			})
 		}
 	}
 
-	return err
+	return resources, err
 }
 
-// DeleteResources takes a list of Unstructured interfaces and 'objects' and performs a 'Foreground delete' upon them. See https://kubernetes.io/docs/concepts/architecture/garbage-collection/#foreground-deletion for more information about delete types.
-func (k *K8sClients) DeleteResources(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured) (err error) {
-	for i, ri := range interfaces {
-		obj := objects[i]
-		propagation := metav1.DeletePropagationForeground
-
-		fmt.Printf("Deleting %s %s\n", obj.GetKind(), obj.GetName())
-		err = ri.Delete(ctx, obj.GetName(), metav1.DeleteOptions{
-			PropagationPolicy: &propagation,
-		})
+// ObjectsFromBytes  Parses a yaml or json manifest into Unstructured objects, without talking to the cluster at all.  Nothing is resolved against discovery, so it works offline, and on manifests full of custom resources whose CRDs aren't installed yet.  Namespaces are left exactly as written.  Get the interfaces later, say once the CRDs are applied, with ResourceInterfaceForObject().
+func ObjectsFromBytes(yamlBytes []byte) (objects []*unstructured.Unstructured, err error) {
+	return objectsFromReader(bytes.NewReader(yamlBytes))
+}
+
+// objectsFromReader  Decodes every document in a yaml or json stream, gzipped or not, into Unstructured objects.  Lists are expanded into their items.
+func objectsFromReader(r io.Reader) (objects []*unstructured.Unstructured, err error) {
+	objects = make([]*unstructured.Unstructured, 0)
+
+	r, err = decompress(r)
+	if err != nil {
+		return objects, err
+	}
+
+	r, err = trimJSONPrefix(r)
+	if err != nil {
+		return objects, err
+	}
+
+	decoder := yamlutil.NewYAMLOrJSONDecoder(r, 100)
+	for document := 1; ; document++ {
+		var rawObj runtime.RawExtension
+		err = decoder.Decode(&rawObj)
 		if err != nil {
-			err = errors.Wrapf(err, "failed deleting %s kind %s", obj.GetName(), obj.GetKind())
-			return err
+			// io.EOF is the normal end of the stream.  Anything else is a malformed document, and carrying on would hand back a silently truncated list.
+			if err == io.EOF {
+				err = nil
+				break
+			}
+
+			err = errors.Wrapf(err, "failed parsing document %d", document)
+			return objects, err
+		}
+
+		// a document that's only comments, or nothing at all between separators, is fine.  kubectl skips them too.
+		raw := bytes.TrimSpace(rawObj.Raw)
+		if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+			continue
+		}
+
+		obj, _, err := yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme).Decode(rawObj.Raw, nil, nil)
+		if err != nil {
+			err = errors.Wrapf(err, "failed decoding document %d", document)
+			return objects, err
+		}
+
+		// A List, be it kind: List or something like the ConfigMapList from `kubectl get -o yaml`, holds its objects in items.  Load each one separately.
+		if list, ok := obj.(*unstructured.UnstructuredList); ok {
+			for i := range list.Items {
+				item := list.Items[i]
+				objects = append(objects, &item)
+			}
+
+			continue
+		}
+
+		unstructuredMap, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			err = errors.Wrapf(err, "failed converting object unstructured")
+			return objects, err
 		}
+
+		objects = append(objects, &unstructured.Unstructured{Object: unstructuredMap})
 	}
 
-	return err
+	return objects, err
+}
+
+// ApplyResources  Takes a list of Unstructured interfaces and 'objects' and applies them to the cluster.  ApplyResources will try to Get the resources first, and if they already exist, it will Update them.
+func (k *K8sClients) ApplyResources(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured) (err error) {
+	return k.ApplyResourcesWithOptions(ctx, interfaces, objects, ApplyOptions{})
+}
+
+// DeleteResources takes a list of Unstructured interfaces and 'objects' and performs a 'Foreground delete' upon them. See https://kubernetes.io/docs/concepts/architecture/garbage-collection/#foreground-deletion for more information about delete types.  Objects are deleted in reverse order, so the Namespace at the top of a manifest goes last.
+func (k *K8sClients) DeleteResources(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured) (err error) {
+	return k.DeleteResourcesWithOptions(ctx, interfaces, objects, DeleteOptions{})
 }
@@ -10,16 +10,27 @@ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLI
 package k8s_utility_client
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/pem"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -61,6 +72,38 @@ func setUp() {
 
 }
 
+// testConfigMap  Creates a simple Unstructured ConfigMap for tests that don't need a live cluster.
+func testConfigMap(name string, namespace string) (obj *unstructured.Unstructured) {
+	obj = &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+			},
+			"data": map[string]interface{}{
+				"foo": "bar",
+			},
+		},
+	}
+
+	return obj
+}
+
+// testRESTMapper  Creates a RESTMapper that knows a handful of built-in kinds, for tests that don't need a live cluster.
+func testRESTMapper() (mapper meta.RESTMapper) {
+	m := meta.NewDefaultRESTMapper(nil)
+	m.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	m.Add(schema.GroupVersionKind{Version: "v1", Kind: "Service"}, meta.RESTScopeNamespace)
+	m.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	m.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	m.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot)
+	m.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot)
+
+	return m
+}
+
 func tearDown() {
 	// Clean up the temp dir
 	if _, err := os.Stat(tmpDir); !os.IsNotExist(err) {
@@ -68,6 +111,308 @@ func tearDown() {
 	}
 }
 
+func TestNamespaceFromBytes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{
+			"trailing newline",
+			[]byte("default\n"),
+			"default",
+		},
+		{
+			"surrounding whitespace",
+			[]byte("  kube-system \r\n"),
+			"kube-system",
+		},
+		{
+			"clean",
+			[]byte("foo"),
+			"foo",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, namespaceFromBytes(tc.input), "Namespace was not trimmed.")
+		})
+	}
+}
+
+func TestCurrentNamespace(t *testing.T) {
+	testCases := []struct {
+		name      string
+		namespace string
+		expected  string
+	}{
+		{
+			"empty",
+			"",
+			DEFAULT_NAMESPACE,
+		},
+		{
+			"set",
+			"kube-system",
+			"kube-system",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &K8sClients{Namespace: tc.namespace}
+			assert.Equal(t, tc.expected, client.CurrentNamespace(), "Unexpected current namespace.")
+		})
+	}
+}
+
+func TestKubeconfigEnvVar(t *testing.T) {
+	if _, err := os.Stat(IN_POD_NAMESPACE_FILE); !os.IsNotExist(err) {
+		t.Skip("running in a k8s pod.  The KUBECONFIG environment variable is not consulted in cluster.")
+	}
+
+	kubeconfig := filepath.Join(tmpDir, "kubeconfig")
+
+	b, err := os.ReadFile("test_fixtures/kubeconfig.yaml")
+	if err != nil {
+		t.Fatalf("failed reading kubeconfig fixture: %s", err)
+	}
+
+	err = os.WriteFile(kubeconfig, b, 0600)
+	if err != nil {
+		t.Fatalf("failed writing temp kubeconfig: %s", err)
+	}
+
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	client, err := NewK8sClients()
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	assert.Equal(t, "https://alpha.example.com:6443", client.K8SConfig.Host, "Client did not load the config file from KUBECONFIG.")
+	assert.Equal(t, "alpha-ns", client.Namespace, "Client namespace was not read from the KUBECONFIG current context.")
+}
+
+func TestNewK8sClientsWithConfigPath(t *testing.T) {
+	testCases := []struct {
+		name      string
+		path      string
+		host      string
+		namespace string
+		errors    bool
+	}{
+		{
+			"fixture kubeconfig",
+			"test_fixtures/kubeconfig.yaml",
+			"https://alpha.example.com:6443",
+			"alpha-ns",
+			false,
+		},
+		{
+			"missing kubeconfig",
+			"test_fixtures/nonexistent.yaml",
+			"",
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClientsWithConfigPath(tc.path)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error loading %s", tc.path)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			assert.Equal(t, tc.host, client.K8SConfig.Host, "Client did not load the expected cluster.")
+			assert.Equal(t, tc.namespace, client.Namespace, "Client namespace does not match the current context.")
+			assert.NotNil(t, client.ClientSet, "ClientSet was not created.")
+			assert.NotNil(t, client.DynamicClient, "DynamicClient was not created.")
+		})
+	}
+}
+
+func TestNewK8sClientsFromKubeconfig(t *testing.T) {
+	fixture, err := os.ReadFile("test_fixtures/kubeconfig.yaml")
+	if err != nil {
+		t.Fatalf("failed reading kubeconfig fixture: %s", err)
+	}
+
+	testCases := []struct {
+		name      string
+		data      []byte
+		host      string
+		namespace string
+		errors    bool
+	}{
+		{
+			"fixture kubeconfig",
+			fixture,
+			"https://alpha.example.com:6443",
+			"alpha-ns",
+			false,
+		},
+		{
+			"garbage",
+			[]byte("this is not a kubeconfig"),
+			"",
+			"",
+			true,
+		},
+		{
+			"empty",
+			[]byte{},
+			"",
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClientsFromKubeconfig(tc.data)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error loading kubeconfig")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			assert.Equal(t, tc.host, client.K8SConfig.Host, "Client did not load the expected cluster.")
+			assert.Equal(t, tc.namespace, client.Namespace, "Client namespace does not match the current context.")
+			assert.NotNil(t, client.ClientSet, "ClientSet was not created.")
+			assert.NotNil(t, client.DynamicClient, "DynamicClient was not created.")
+		})
+	}
+}
+
+func TestNewK8sClientsWithToken(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer not-a-real-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"major": "1", "minor": "25", "gitVersion": "v1.25.4"}`))
+	}))
+	defer server.Close()
+
+	caData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	testCases := []struct {
+		name   string
+		token  string
+		caData []byte
+		errors bool
+	}{
+		{
+			"valid token",
+			"not-a-real-token",
+			caData,
+			false,
+		},
+		{
+			"wrong token",
+			"some-other-token",
+			caData,
+			true,
+		},
+		{
+			"untrusted server",
+			"not-a-real-token",
+			nil,
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClientsWithToken(server.URL, tc.caData, tc.token)
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			assert.Equal(t, server.URL, client.K8SConfig.Host, "Host not set on the rest.Config.")
+			assert.Equal(t, tc.token, client.K8SConfig.BearerToken, "Token not set on the rest.Config.")
+			assert.Equal(t, "default", client.Namespace, "Client namespace does not match expectations.")
+
+			// the server only answers to the right token, over TLS it can verify
+			info, err := client.ServerVersion()
+			if tc.errors {
+				assert.Error(t, err, "Expected the request to be rejected.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed getting server version: %s", err)
+			}
+
+			assert.Equal(t, "v1.25.4", info.GitVersion, "Server version does not match expectations.")
+		})
+	}
+}
+
+func TestNewK8sClientsWithContext(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contextName string
+		host        string
+		namespace   string
+		errors      bool
+	}{
+		{
+			"current context",
+			"alpha",
+			"https://alpha.example.com:6443",
+			"alpha-ns",
+			false,
+		},
+		{
+			"other context",
+			"beta",
+			"https://beta.example.com:6443",
+			"beta-ns",
+			false,
+		},
+		{
+			"missing context",
+			"gamma",
+			"",
+			"",
+			true,
+		},
+	}
+
+	t.Setenv("KUBECONFIG", "test_fixtures/kubeconfig.yaml")
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClientsWithContext(tc.contextName)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error selecting context %s", tc.contextName)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			assert.Equal(t, tc.host, client.K8SConfig.Host, "Client did not select the expected cluster.")
+			assert.Equal(t, tc.namespace, client.Namespace, "Client namespace does not match the selected context.")
+		})
+	}
+}
+
 func TestResourceLoading(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -115,6 +460,594 @@ func TestResourceLoading(t *testing.T) {
 	}
 }
 
+func TestResourcesAndObjectsFromReader(t *testing.T) {
+	testCases := []struct {
+		name     string
+		manifest string
+		expected []string
+	}{
+		{
+			"two documents",
+			`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: reader-one
+data:
+  foo: bar
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: reader-two
+spec:
+  ports:
+    - port: 80
+`,
+			[]string{"ConfigMap/reader-one", "Service/reader-two"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromReader(strings.NewReader(tc.manifest))
+			if err != nil {
+				t.Fatalf("failed to load manifest: %s", err)
+			}
+
+			actual := make([]string, 0)
+			for _, o := range objects {
+				actual = append(actual, fmt.Sprintf("%s/%s", o.GetKind(), o.GetName()))
+			}
+
+			assert.Equal(t, tc.expected, actual, "Loaded objects do not match expectations.")
+			assert.Equal(t, len(objects), len(interfaces), "Interfaces and objects are not aligned.")
+		})
+	}
+}
+
+func TestResourcesAndObjectsFromReaderJSONStream(t *testing.T) {
+	testCases := []struct {
+		name     string
+		manifest string
+		expected []string
+	}{
+		{
+			"newline delimited",
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "json-one"}, "data": {"foo": "bar"}}
+{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "json-two"}, "spec": {"ports": [{"port": 80}]}}
+`,
+			[]string{"ConfigMap/json-one", "Service/json-two"},
+		},
+		{
+			"back to back",
+			`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "json-one"}}{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "json-two"}}`,
+			[]string{"ConfigMap/json-one", "Service/json-two"},
+		},
+		{
+			"pretty printed",
+			`
+{
+  "apiVersion": "v1",
+  "kind": "ConfigMap",
+  "metadata": {
+    "name": "json-one"
+  }
+}
+{
+  "apiVersion": "v1",
+  "kind": "Service",
+  "metadata": {
+    "name": "json-two"
+  }
+}
+`,
+			[]string{"ConfigMap/json-one", "Service/json-two"},
+		},
+		{
+			"padded past the sniffing buffer",
+			strings.Repeat("\n", 200) + `{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "json-one"}}
+{"apiVersion": "v1", "kind": "Service", "metadata": {"name": "json-two"}}`,
+			[]string{"ConfigMap/json-one", "Service/json-two"},
+		},
+		{
+			"indented yaml",
+			`  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: yaml-one
+`,
+			[]string{"ConfigMap/yaml-one"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromReader(strings.NewReader(tc.manifest))
+			if err != nil {
+				t.Fatalf("failed to load manifest: %s", err)
+			}
+
+			actual := make([]string, 0)
+			for _, o := range objects {
+				actual = append(actual, fmt.Sprintf("%s/%s", o.GetKind(), o.GetName()))
+			}
+
+			assert.Equal(t, tc.expected, actual, "Loaded objects do not match expectations.")
+			assert.Equal(t, len(objects), len(interfaces), "Interfaces and objects are not aligned.")
+		})
+	}
+}
+
+func TestResourcesAndObjectsFromReaderMalformed(t *testing.T) {
+	testCases := []struct {
+		name     string
+		manifest string
+		document string
+	}{
+		{
+			"malformed first document",
+			`---
+apiVersion: v1
+kind: ConfigMap
+metadata: [name: broken
+`,
+			"document 1",
+		},
+		{
+			"valid then malformed",
+			`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: reader-one
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: reader-two
+  labels: {unclosed: true
+`,
+			"document 2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+			_, _, err := client.ResourcesAndObjectsFromReader(strings.NewReader(tc.manifest))
+			if assert.Error(t, err, "Expected an error parsing a malformed manifest.") {
+				assert.Contains(t, err.Error(), tc.document, "Error does not say which document failed.")
+			}
+		})
+	}
+}
+
+func TestResourcesAndObjectsFromReaderGzip(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fixture string
+	}{
+		{
+			"configmap",
+			"test_fixtures/configmap.yaml",
+		},
+		{
+			"multiple documents",
+			"test_fixtures/resources.yaml",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+			manifest, err := os.ReadFile(tc.fixture)
+			if err != nil {
+				t.Fatalf("failed reading %s: %s", tc.fixture, err)
+			}
+
+			var compressed bytes.Buffer
+			zw := gzip.NewWriter(&compressed)
+			_, err = zw.Write(manifest)
+			if err != nil {
+				t.Fatalf("failed compressing %s: %s", tc.fixture, err)
+			}
+
+			err = zw.Close()
+			if err != nil {
+				t.Fatalf("failed compressing %s: %s", tc.fixture, err)
+			}
+
+			_, expected, err := client.ResourcesAndObjectsFromBytes(manifest)
+			if err != nil {
+				t.Fatalf("failed to load manifest: %s", err)
+			}
+
+			interfaces, actual, err := client.ResourcesAndObjectsFromReader(&compressed)
+			if err != nil {
+				t.Fatalf("failed to load gzipped manifest: %s", err)
+			}
+
+			assert.Equal(t, expected, actual, "Gzipped objects do not match the uncompressed ones.")
+			assert.Equal(t, len(actual), len(interfaces), "Interfaces and objects are not aligned.")
+		})
+	}
+}
+
+func TestResourcesAndObjectsFromList(t *testing.T) {
+	testCases := []struct {
+		name     string
+		manifest string
+		expected []string
+	}{
+		{
+			"list and separate document",
+			"test_fixtures/list.yaml",
+			[]string{"ConfigMap/utility-client-listed", "Service/utility-client-listed", "ConfigMap/utility-client-separate"},
+		},
+		{
+			"typed list",
+			`{"apiVersion": "v1", "kind": "ConfigMapList", "items": [{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "one"}}, {"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "two"}}]}`,
+			[]string{"ConfigMap/one", "ConfigMap/two"},
+		},
+		{
+			"empty list",
+			`{"apiVersion": "v1", "kind": "List", "items": []}`,
+			[]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+			manifest := []byte(tc.manifest)
+			if strings.HasPrefix(tc.manifest, "test_fixtures/") {
+				b, err := os.ReadFile(tc.manifest)
+				if err != nil {
+					t.Fatalf("failed reading %s: %s", tc.manifest, err)
+				}
+
+				manifest = b
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromBytes(manifest)
+			if err != nil {
+				t.Fatalf("failed loading list: %s", err)
+			}
+
+			actual := make([]string, 0)
+			for _, obj := range objects {
+				actual = append(actual, fmt.Sprintf("%s/%s", obj.GetKind(), obj.GetName()))
+				assert.Equal(t, "default", obj.GetNamespace(), "List item %s was not put in a namespace.", obj.GetName())
+			}
+
+			assert.Equal(t, tc.expected, actual, "List items were not loaded separately.")
+			assert.Equal(t, len(objects), len(interfaces), "Interfaces and objects are not aligned.")
+		})
+	}
+}
+
+func TestLoadedResourcesFromBytes(t *testing.T) {
+	testCases := []struct {
+		name      string
+		fileName  string
+		gvks      []string
+		resources []string
+	}{
+		{
+			"several documents",
+			"test_fixtures/resources.yaml",
+			[]string{"apps/v1, Kind=Deployment", "/v1, Kind=Service"},
+			[]string{"deployments", "services"},
+		},
+		{
+			"custom resource with its crd",
+			"test_fixtures/crd-with-widget.yaml",
+			[]string{"apiextensions.k8s.io/v1, Kind=CustomResourceDefinition", "utility-client.example.com/v1, Kind=Widget"},
+			[]string{"customresourcedefinitions", "widgets"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := os.ReadFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed reading %s: %s", tc.fileName, err)
+			}
+
+			client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+			resources, err := client.LoadedResourcesFromBytes(b)
+			if err != nil {
+				t.Fatalf("failed loading %s: %s", tc.fileName, err)
+			}
+
+			gvks := make([]string, 0)
+			names := make([]string, 0)
+			for _, r := range resources {
+				gvks = append(gvks, r.GVK.String())
+				names = append(names, r.Mapping.Resource.Resource)
+
+				assert.Equal(t, r.Object.GroupVersionKind(), r.GVK, "GVK does not line up with its object.")
+				assert.NotNil(t, r.Interface, "Missing interface for %s.", r.Object.GetName())
+			}
+
+			assert.Equal(t, tc.gvks, gvks, "Loaded GVKs do not match expectations.")
+			assert.Equal(t, tc.resources, names, "Mapped resources do not match expectations.")
+
+			// the parallel slices from ResourcesAndObjectsFromBytes line up with the richer result
+			_, objects, err := client.ResourcesAndObjectsFromBytes(b)
+			if err != nil {
+				t.Fatalf("failed loading %s as parallel slices: %s", tc.fileName, err)
+			}
+
+			if assert.Equal(t, len(resources), len(objects), "Loaders disagree on the number of objects.") {
+				for i, o := range objects {
+					assert.Equal(t, o.GroupVersionKind(), resources[i].GVK, "Objects are out of order.")
+				}
+			}
+		})
+	}
+}
+
+func TestObjectsFromBytes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		manifest string
+		expected []string
+		errors   bool
+	}{
+		{
+			"custom resource without its crd",
+			"test_fixtures/widget.yaml",
+			[]string{"utility-client.example.com/v1, Kind=Widget"},
+			false,
+		},
+		{
+			"several documents",
+			"test_fixtures/resources.yaml",
+			[]string{"apps/v1, Kind=Deployment", "/v1, Kind=Service"},
+			false,
+		},
+		{
+			"list",
+			"test_fixtures/list.yaml",
+			[]string{"/v1, Kind=ConfigMap", "/v1, Kind=Service", "/v1, Kind=ConfigMap"},
+			false,
+		},
+		{
+			"empty documents",
+			"---\n# Source: chart/templates/nothing.yaml\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: between\n---\n---\n",
+			[]string{"/v1, Kind=ConfigMap"},
+			false,
+		},
+		{
+			"missing kind",
+			"apiVersion: v1\nmetadata:\n  name: kindless\n",
+			nil,
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			manifest := []byte(tc.manifest)
+			if strings.HasPrefix(tc.manifest, "test_fixtures/") {
+				b, err := os.ReadFile(tc.manifest)
+				if err != nil {
+					t.Fatalf("failed reading %s: %s", tc.manifest, err)
+				}
+
+				manifest = b
+			}
+
+			// no client, so there's nothing to do discovery with
+			objects, err := ObjectsFromBytes(manifest)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error parsing a malformed manifest.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed parsing manifest offline: %s", err)
+			}
+
+			kinds := make([]string, 0)
+			for _, obj := range objects {
+				kinds = append(kinds, obj.GroupVersionKind().String())
+			}
+
+			assert.Equal(t, tc.expected, kinds, "Unexpected objects parsed.")
+		})
+	}
+}
+
+func TestResourcesAndObjectsFromDirectory(t *testing.T) {
+	testCases := []struct {
+		name      string
+		dir       string
+		recursive bool
+		expected  []string
+	}{
+		{
+			"top level only",
+			"test_fixtures/manifests",
+			false,
+			[]string{"ConfigMap/directory-top", "Service/directory-json"},
+		},
+		{
+			"recursive",
+			"test_fixtures/manifests",
+			true,
+			[]string{"ConfigMap/directory-top", "ConfigMap/directory-nested", "Service/directory-json"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromDirectory(tc.dir, tc.recursive)
+			if err != nil {
+				t.Fatalf("failed to load directory %s: %s", tc.dir, err)
+			}
+
+			actual := make([]string, 0)
+			for _, o := range objects {
+				actual = append(actual, fmt.Sprintf("%s/%s", o.GetKind(), o.GetName()))
+			}
+
+			assert.Equal(t, tc.expected, actual, "Loaded objects do not match expectations.")
+			assert.Equal(t, len(objects), len(interfaces), "Interfaces and objects are not aligned.")
+		})
+	}
+}
+
+func TestResourcesAndObjectsFromURL(t *testing.T) {
+	testCases := []struct {
+		name     string
+		path     string
+		expected []string
+		errors   bool
+	}{
+		{
+			"manifest",
+			"/resources.yaml",
+			[]string{"Deployment/nginx", "Service/nginx"},
+			false,
+		},
+		{
+			"not found",
+			"/missing.yaml",
+			nil,
+			true,
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/resources.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+
+		http.ServeFile(w, r, "test_fixtures/resources.yaml")
+	}))
+
+	defer server.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			_, objects, err := client.ResourcesAndObjectsFromURL(context.TODO(), server.URL+tc.path)
+			if tc.errors {
+				if assert.Error(t, err, "Expected an error fetching %s", tc.path) {
+					assert.Contains(t, err.Error(), "404", "Error does not include the status code.")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to load manifest from url: %s", err)
+			}
+
+			actual := make([]string, 0)
+			for _, o := range objects {
+				actual = append(actual, fmt.Sprintf("%s/%s", o.GetKind(), o.GetName()))
+			}
+
+			assert.Equal(t, tc.expected, actual, "Loaded objects do not match expectations.")
+		})
+	}
+}
+
+func TestResourcesAndObjectsFromBytesInNamespace(t *testing.T) {
+	manifest := `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: override-unset
+data:
+  foo: bar
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: override-set
+  namespace: elsewhere
+data:
+  foo: bar
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: override-cluster-scoped
+rules: []
+`
+
+	testCases := []struct {
+		name      string
+		namespace string
+		expected  map[string]string
+	}{
+		{
+			"no override",
+			"",
+			map[string]string{
+				"override-unset":          "default",
+				"override-set":            "elsewhere",
+				"override-cluster-scoped": "",
+			},
+		},
+		{
+			"override",
+			"utility-client-test",
+			map[string]string{
+				"override-unset":          "utility-client-test",
+				"override-set":            "utility-client-test",
+				"override-cluster-scoped": "",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			_, objects, err := client.ResourcesAndObjectsFromBytesInNamespace([]byte(manifest), tc.namespace)
+			if err != nil {
+				t.Fatalf("failed to load manifest: %s", err)
+			}
+
+			actual := make(map[string]string)
+			for _, o := range objects {
+				actual[o.GetName()] = o.GetNamespace()
+			}
+
+			assert.Equal(t, tc.expected, actual, "Object namespaces do not match expectations.")
+		})
+	}
+}
+
 func TestApplyResources(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -146,17 +1079,12 @@ func TestApplyResources(t *testing.T) {
 				t.Errorf("failed to apply resources: %s", err)
 			}
 
-			sleeptime := 10
-			fmt.Printf("Sleeping %d seconds before attempting verification.\n", sleeptime)
-			time.Sleep(time.Duration(sleeptime) * time.Second)
-
 			fmt.Printf("Verifying resources in k8s.\n")
-			for i, obj := range objects {
-				ri := interfaces[i]
-
-				o, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+			for _, obj := range objects {
+				o, err := client.GetEventually(ctx, obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName(), 30*time.Second)
 				if err != nil {
-					t.Errorf("failed getting resource %s kind %s", obj.GetName(), obj.GetKind())
+					t.Errorf("failed getting resource %s kind %s: %s", obj.GetName(), obj.GetKind(), err)
+					continue
 				}
 
 				assert.Equal(t, obj.GetName(), o.GetName(), "Created Resource name doesn't match expectation.")
@@ -171,8 +1099,11 @@ func TestApplyResources(t *testing.T) {
 				t.Errorf("failed deleting resources: %s", err)
 			}
 
-			fmt.Printf("Sleeping %d seconds before attempting verification of clean up.\n", sleeptime)
-			time.Sleep(time.Duration(sleeptime) * time.Second)
+			fmt.Printf("Waiting for resources to be deleted.\n")
+			err = client.WaitForDeletion(ctx, interfaces, objects, 2*time.Minute)
+			if err != nil {
+				t.Errorf("failed waiting for deletion: %s", err)
+			}
 
 			for i, obj := range objects {
 				ri := interfaces[i]
@@ -185,3 +1116,31 @@ func TestApplyResources(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkResourcesAndObjectsFromBytes(b *testing.B) {
+	// 50 resources in a single manifest.  Discovery should only happen once per load, regardless of the number of resources.
+	var manifest strings.Builder
+	for i := 0; i < 50; i++ {
+		manifest.WriteString(fmt.Sprintf("---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: bench-%d\ndata:\n  foo: bar\n", i))
+	}
+
+	yamlBytes := []byte(manifest.String())
+
+	client, err := NewK8sClients()
+	if err != nil {
+		b.Fatalf("failed creating client: %s", err)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, objects, err := client.ResourcesAndObjectsFromBytes(yamlBytes)
+		if err != nil {
+			b.Fatalf("failed loading manifest: %s", err)
+		}
+
+		if len(objects) != 50 {
+			b.Fatalf("expected 50 objects, got %d", len(objects))
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"strings"
+	"time"
+)
+
+// CRD_ESTABLISHED_TIMEOUT  How long ApplyResources waits for a CRD to become established before applying custom resources of the kind it defines.
+const CRD_ESTABLISHED_TIMEOUT = time.Minute
+
+// crdGVR  The resource for CustomResourceDefinitions.  Accessed through the dynamic client, so we don't need to pull in the apiextensions clientset.
+var crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// WaitForCRDEstablished  Polls the named CustomResourceDefinition until its Established condition is True, or the timeout fires.  Custom resources of the new kind can't be applied until then.  Once established, the RESTMapper is reset so it can resolve the new kind.
+func (k *K8sClients) WaitForCRDEstablished(ctx context.Context, crdName string, timeout time.Duration) (err error) {
+	status := "not found"
+
+	err = wait.PollImmediateWithContext(ctx, POLL_INTERVAL, timeout, func(ctx context.Context) (done bool, err error) {
+		crd, err := k.DynamicClient.Resource(crdGVR).Get(ctx, crdName, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				status = "not found"
+				return false, nil
+			}
+
+			err = errors.Wrapf(err, "failed getting crd %s", crdName)
+			return false, err
+		}
+
+		done, status = crdEstablished(crd)
+
+		return done, nil
+	})
+
+	if err == wait.ErrWaitTimeout {
+		if ctx.Err() != nil {
+			err = errors.Wrapf(ctx.Err(), "stopped waiting for crd %s to become established: %s", crdName, status)
+			return err
+		}
+
+		err = errors.New(fmt.Sprintf("timed out after %s waiting for crd %s to become established: %s", timeout, crdName, status))
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	k.resetMapper()
+
+	return err
+}
+
+// crdEstablished  Reports whether a CustomResourceDefinition's Established condition is True, along with a short description of its state if it isn't.
+func crdEstablished(crd *unstructured.Unstructured) (established bool, status string) {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Established" {
+			continue
+		}
+
+		if condition["status"] == "True" {
+			return true, "established"
+		}
+
+		return false, fmt.Sprintf("not established: %v", condition["message"])
+	}
+
+	return false, "no Established condition yet"
+}
+
+// isCRD  Reports whether the object is a CustomResourceDefinition.
+func isCRD(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+
+	return gvk.Group == crdGVR.Group && gvk.Kind == "CustomResourceDefinition"
+}
+
+// crdDefinesAny  Reports whether the CRD defines the kind of any of the objects.
+func crdDefinesAny(crd *unstructured.Unstructured, objects []*unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		if gvk.Group == group && gvk.Kind == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// crdRESTMapper  Builds a RESTMapper for the kinds defined by any CustomResourceDefinitions among the objects.  Lets a CRD and its custom resources be loaded from the same manifest, before the cluster has heard of the new kinds.
+func crdRESTMapper(objects []*unstructured.Unstructured) (mapper *meta.DefaultRESTMapper) {
+	mapper = meta.NewDefaultRESTMapper(nil)
+
+	for _, obj := range objects {
+		if !isCRD(obj) {
+			continue
+		}
+
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		plural, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "plural")
+		singular, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "singular")
+		scope, _, _ := unstructured.NestedString(obj.Object, "spec", "scope")
+
+		if singular == "" {
+			singular = strings.ToLower(kind)
+		}
+
+		restScope := meta.RESTScopeRoot
+		if scope == "Namespaced" {
+			restScope = meta.RESTScopeNamespace
+		}
+
+		versions, _, _ := unstructured.NestedSlice(obj.Object, "spec", "versions")
+		for _, v := range versions {
+			version, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			name, _ := version["name"].(string)
+			gv := schema.GroupVersion{Group: group, Version: name}
+
+			mapper.AddSpecific(gv.WithKind(kind), gv.WithResource(plural), gv.WithResource(singular), restScope)
+		}
+	}
+
+	return mapper
+}
@@ -0,0 +1,264 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCRD(name string, established string) (obj *unstructured.Unstructured) {
+	obj = &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata": map[string]interface{}{
+				"name": name,
+			},
+		},
+	}
+
+	if established != "" {
+		_ = unstructured.SetNestedSlice(obj.Object, []interface{}{
+			map[string]interface{}{
+				"type":   "NamesAccepted",
+				"status": "True",
+			},
+			map[string]interface{}{
+				"type":    "Established",
+				"status":  established,
+				"message": "the initial names have been accepted",
+			},
+		}, "status", "conditions")
+	}
+
+	return obj
+}
+
+func TestWaitForCRDEstablishedFake(t *testing.T) {
+	testCases := []struct {
+		name        string
+		established string
+		errors      bool
+	}{
+		{
+			"established",
+			"True",
+			false,
+		},
+		{
+			"not established",
+			"False",
+			true,
+		},
+		{
+			"no conditions",
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testCRD("widgets.utility-client.example.com", tc.established))
+			client := &K8sClients{DynamicClient: dc}
+
+			err := client.WaitForCRDEstablished(context.TODO(), "widgets.utility-client.example.com", 100*time.Millisecond)
+			if tc.errors {
+				assert.Error(t, err, "Expected a timeout waiting for the crd.")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error waiting for an established crd.")
+		})
+	}
+}
+
+func TestWaitForCRDEstablished(t *testing.T) {
+	client, err := NewK8sClients()
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	ctx := context.TODO()
+
+	crdInterfaces, crdObjects, err := client.ResourcesAndObjectsFromFile("test_fixtures/crd.yaml")
+	if err != nil {
+		t.Fatalf("failed to load crd: %s", err)
+	}
+
+	fmt.Printf("Creating crd in k8s.\n")
+	err = client.ApplyResources(ctx, crdInterfaces, crdObjects)
+	if err != nil {
+		t.Fatalf("failed to apply crd: %s", err)
+	}
+
+	defer func() {
+		fmt.Printf("Cleaning up crd in k8s.\n")
+		err = client.DeleteResources(ctx, crdInterfaces, crdObjects)
+		if err != nil {
+			t.Errorf("failed deleting crd: %s", err)
+		}
+	}()
+
+	err = client.WaitForCRDEstablished(ctx, crdObjects[0].GetName(), time.Minute)
+	if err != nil {
+		t.Fatalf("crd was not established: %s", err)
+	}
+
+	// the new kind is resolvable, and applies, as soon as the wait returns
+	interfaces, objects, err := client.ResourcesAndObjectsFromFile("test_fixtures/widget.yaml")
+	if err != nil {
+		t.Fatalf("failed to load custom resource: %s", err)
+	}
+
+	err = client.ApplyResources(ctx, interfaces, objects)
+	assert.NoError(t, err, "Custom resource failed to apply after the crd was established.")
+
+	err = client.DeleteResources(ctx, interfaces, objects)
+	if err != nil {
+		t.Errorf("failed deleting custom resource: %s", err)
+	}
+}
+
+func TestResourcesAndObjectsWithCRD(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+		reversed bool
+	}{
+		{
+			"crd first",
+			"test_fixtures/crd-with-widget.yaml",
+			false,
+		},
+		{
+			"custom resource first",
+			"test_fixtures/crd-with-widget.yaml",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := os.ReadFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed reading %s: %s", tc.fileName, err)
+			}
+
+			if tc.reversed {
+				docs := strings.Split(string(b), "---\n")
+				for i, j := 0, len(docs)-1; i < j; i, j = i+1, j-1 {
+					docs[i], docs[j] = docs[j], docs[i]
+				}
+				b = []byte(strings.Join(docs, "---\n"))
+			}
+
+			client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+			_, objects, err := client.ResourcesAndObjectsFromBytes(b)
+			if err != nil {
+				t.Fatalf("failed loading crd and custom resource together: %s", err)
+			}
+
+			kinds := make([]string, 0)
+			for _, obj := range objects {
+				kinds = append(kinds, obj.GetKind())
+			}
+
+			assert.ElementsMatch(t, []string{"CustomResourceDefinition", "Widget"}, kinds, "Unexpected objects loaded.")
+		})
+	}
+}
+
+func TestCRDRESTMapper(t *testing.T) {
+	client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+	_, objects, err := client.ResourcesAndObjectsFromFile("test_fixtures/crd.yaml")
+	if err != nil {
+		t.Fatalf("failed loading crd: %s", err)
+	}
+
+	mapper := crdRESTMapper(objects)
+
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: "utility-client.example.com", Kind: "Widget"}, "v1")
+	if err != nil {
+		t.Fatalf("failed resolving kind defined by crd: %s", err)
+	}
+
+	assert.Equal(t, schema.GroupVersionResource{Group: "utility-client.example.com", Version: "v1", Resource: "widgets"}, mapping.Resource, "Unexpected resource for crd kind.")
+	assert.Equal(t, meta.RESTScopeNameNamespace, mapping.Scope.Name(), "Unexpected scope for crd kind.")
+}
+
+func TestApplyResourcesWithCRDFake(t *testing.T) {
+	dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	// the fake has no apiextensions controller, so establish new CRDs ourselves
+	dc.PrependReactor("create", "customresourcedefinitions", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+		crd := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		_ = unstructured.SetNestedSlice(crd.Object, []interface{}{
+			map[string]interface{}{"type": "Established", "status": "True"},
+		}, "status", "conditions")
+
+		return false, nil, nil
+	})
+
+	client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+	interfaces, objects, err := client.ResourcesAndObjectsFromFile("test_fixtures/crd-with-widget.yaml")
+	if err != nil {
+		t.Fatalf("failed loading crd and custom resource together: %s", err)
+	}
+
+	err = client.ApplyResources(context.TODO(), interfaces, objects)
+	if err != nil {
+		t.Fatalf("failed applying crd and custom resource together: %s", err)
+	}
+
+	gvr := schema.GroupVersionResource{Group: "utility-client.example.com", Version: "v1", Resource: "widgets"}
+	_, err = dc.Resource(gvr).Namespace("default").Get(context.TODO(), "utility-client-widget", metav1.GetOptions{})
+	assert.NoError(t, err, "Custom resource was not applied.")
+}
+
+func TestApplyResourcesWithCRD(t *testing.T) {
+	client, err := NewK8sClients()
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	ctx := context.TODO()
+
+	interfaces, objects, err := client.ResourcesAndObjectsFromFile("test_fixtures/crd-with-widget.yaml")
+	if err != nil {
+		t.Fatalf("failed loading crd and custom resource together: %s", err)
+	}
+
+	fmt.Printf("Creating crd and custom resource in k8s.\n")
+	err = client.ApplyResources(ctx, interfaces, objects)
+	assert.NoError(t, err, "Failed applying crd and custom resource together.")
+
+	fmt.Printf("Cleaning up crd and custom resource in k8s.\n")
+	err = client.DeleteResources(ctx, interfaces, objects)
+	if err != nil {
+		t.Errorf("failed deleting resources: %s", err)
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"time"
+)
+
+// forceDeleteGrace  How long ForceDelete lets a resource terminate on its own before stripping its finalizers.
+var forceDeleteGrace = 10 * time.Second
+
+// DeleteOptions  Controls how DeleteResourcesWithOptions deletes objects from the cluster.  The zero value behaves exactly like DeleteResources.
+type DeleteOptions struct {
+	// IgnoreNotFound  Skip objects that are already gone instead of erroring, and carry on deleting the rest.  Handy when a cascading delete has already removed some of them.
+	IgnoreNotFound bool
+	// PropagationPolicy  How dependents of the deleted objects are garbage collected.  Defaults to metav1.DeletePropagationForeground.  See https://kubernetes.io/docs/concepts/architecture/garbage-collection/ for details.
+	//
+	//   metav1.DeletePropagationForeground  The object is kept, with a deletionTimestamp, until all of its dependents with blockOwnerDeletion have been deleted.
+	//   metav1.DeletePropagationBackground  The object is deleted immediately, and the garbage collector deletes the dependents afterwards.
+	//   metav1.DeletePropagationOrphan      The object is deleted, and its dependents are left behind without an owner.
+	PropagationPolicy metav1.DeletionPropagation
+	// ContinueOnError  Carry on deleting the rest of the objects when one fails, rather than stopping at the first failure.  Every failure is returned together in a utilerrors.Aggregate, so you can report all of them.
+	ContinueOnError bool
+	// DryRun  Send every delete with DryRun=All.  The server checks the delete would be allowed, RBAC and admission included, but nothing is removed.
+	DryRun bool
+	// GracePeriodSeconds  How long the objects get to terminate gracefully.  Zero deletes immediately.  Nil uses the server's default for each object.
+	GracePeriodSeconds *int64
+}
+
+// propagationPolicy  Returns the propagation policy to send with a delete, defaulting to Foreground.
+func (o DeleteOptions) propagationPolicy() (propagation *metav1.DeletionPropagation) {
+	policy := o.PropagationPolicy
+	if policy == "" {
+		policy = metav1.DeletePropagationForeground
+	}
+
+	return &policy
+}
+
+// dryRun  Returns the DryRun value for a delete.
+func (o DeleteOptions) dryRun() (dryRun []string) {
+	if o.DryRun {
+		dryRun = []string{metav1.DryRunAll}
+	}
+
+	return dryRun
+}
+
+// DeleteResourcesWithOptions  Deletes a list of Unstructured interfaces and 'objects' from the cluster like DeleteResources, but lets the caller choose how via DeleteOptions.  Objects are deleted in the reverse of the order they're listed, so that teardown undoes an apply of the same list.
+func (k *K8sClients) DeleteResourcesWithOptions(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, opts DeleteOptions) (err error) {
+	// only used with ContinueOnError
+	errs := make([]error, 0)
+
+	// Manifests generally list things before whatever depends on them (the Namespace first, ConfigMaps before the Deployments that mount them), so walk the list backwards.
+	for i := len(interfaces) - 1; i >= 0; i-- {
+		ri := interfaces[i]
+		obj := objects[i]
+
+		// stop promptly if we've been cancelled, rather than pressing on until the next API call notices
+		select {
+		case <-ctx.Done():
+			err = errors.Wrapf(ctx.Err(), "stopped deleting resources at %s kind %s", obj.GetName(), obj.GetKind())
+			if opts.ContinueOnError {
+				err = utilerrors.NewAggregate(append(errs, err))
+			}
+
+			return err
+		default:
+		}
+
+		k.log().Info("Deleting", "kind", obj.GetKind(), "name", obj.GetName(), "namespace", obj.GetNamespace())
+		err = retryDelete(ctx, func() error {
+			return ri.Delete(ctx, obj.GetName(), metav1.DeleteOptions{
+				PropagationPolicy:  opts.propagationPolicy(),
+				GracePeriodSeconds: opts.GracePeriodSeconds,
+				DryRun:             opts.dryRun(),
+			})
+		})
+		if err != nil {
+			if opts.IgnoreNotFound && apierrors.IsNotFound(err) {
+				err = nil
+				continue
+			}
+
+			err = errors.Wrapf(err, "failed deleting %s kind %s", obj.GetName(), obj.GetKind())
+			if opts.ContinueOnError {
+				errs = append(errs, err)
+				continue
+			}
+
+			return err
+		}
+	}
+
+	err = utilerrors.NewAggregate(errs)
+
+	return err
+}
+
+// DeleteObject  Deletes a single object, such as one built in code or fetched with GetResource, without the bother of loading it into parallel slices first.  The object's kind is resolved against the cluster just like ApplyObject.  opts are passed straight through to the server.
+func (k *K8sClients) DeleteObject(ctx context.Context, obj *unstructured.Unstructured, opts metav1.DeleteOptions) (err error) {
+	ri, err := k.ResourceInterfaceForObject(obj)
+	if err != nil {
+		return err
+	}
+
+	k.log().Info("Deleting", "kind", obj.GetKind(), "name", obj.GetName(), "namespace", obj.GetNamespace())
+	err = retryDelete(ctx, func() error {
+		return ri.Delete(ctx, obj.GetName(), opts)
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed deleting %s kind %s", obj.GetName(), obj.GetKind())
+		return err
+	}
+
+	return err
+}
+
+// DeleteFromFile  Loads the resources in a yaml or json file, and deletes them with the given options, like `kubectl delete -f`.
+func (k *K8sClients) DeleteFromFile(ctx context.Context, fileName string, opts DeleteOptions) (err error) {
+	interfaces, objects, err := k.ResourcesAndObjectsFromFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	err = k.DeleteResourcesWithOptions(ctx, interfaces, objects, opts)
+
+	return err
+}
+
+// ForceDelete  Deletes a resource, and if it's still stuck Terminating after a short grace, strips its metadata.finalizers so the server can finish removing it.  This is destructive.  Whatever cleanup the finalizers were holding out for, such as a controller releasing cloud resources, is skipped, so only use it on things you know are safe to orphan.  A Namespace is usually held by the kubernetes finalizer in spec.finalizers rather than metadata.finalizers, so for Namespaces that's emptied too, through the finalize subresource.  Anything still in the Namespace is left behind in etcd, and turns up again if a Namespace of the same name is created.  A resource that's already gone is not an error.
+func (k *K8sClients) ForceDelete(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string) (err error) {
+	ri, err := k.resourceInterface(gvk, namespace)
+	if err != nil {
+		return err
+	}
+
+	k.log().Info("Force deleting", "kind", gvk.Kind, "name", name, "namespace", namespace)
+	err = retryTransient(ctx, func() error {
+		return ri.Delete(ctx, name, metav1.DeleteOptions{})
+	})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		err = errors.Wrapf(err, "failed deleting %s kind %s", name, gvk.Kind)
+		return err
+	}
+
+	// give the finalizers a fair chance to do their job before going over their heads
+	err = wait.PollImmediateWithContext(ctx, POLL_INTERVAL, forceDeleteGrace, func(ctx context.Context) (done bool, err error) {
+		_, err = ri.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+
+			err = errors.Wrapf(err, "failed getting %s kind %s", name, gvk.Kind)
+			return false, err
+		}
+
+		return false, nil
+	})
+
+	if err != wait.ErrWaitTimeout {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		err = errors.Wrapf(ctx.Err(), "stopped waiting for %s kind %s to terminate", name, gvk.Kind)
+		return err
+	}
+
+	k.log().Info("Still terminating, removing finalizers", "kind", gvk.Kind, "name", name, "namespace", namespace)
+	patch := []byte(`{"metadata":{"finalizers":null}}`)
+	err = retryTransient(ctx, func() error {
+		_, err := ri.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+		return err
+	})
+	if err != nil {
+		// it may have finished terminating while we weren't looking
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+
+		err = errors.Wrapf(err, "failed removing finalizers from %s kind %s", name, gvk.Kind)
+		return err
+	}
+
+	if gvk.Group == "" && gvk.Kind == "Namespace" {
+		k.log().Info("Finalizing namespace", "name", name)
+		err = retryTransient(ctx, func() error {
+			return finalizeNamespace(ctx, ri, name)
+		})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+
+			err = errors.Wrapf(err, "failed finalizing namespace %s", name)
+			return err
+		}
+	}
+
+	return err
+}
+
+// finalizeNamespace  Empties a Namespace's spec.finalizers.  They can only be changed through the finalize subresource.  The kubernetes finalizer keeps a Namespace Terminating until everything in it has been deleted, which never happens if, say, an aggregated API that serves some of its contents is down.
+func finalizeNamespace(ctx context.Context, ri dynamic.ResourceInterface, name string) (err error) {
+	ns, err := ri.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	finalizers, _, _ := unstructured.NestedSlice(ns.Object, "spec", "finalizers")
+	if len(finalizers) == 0 {
+		return err
+	}
+
+	unstructured.RemoveNestedField(ns.Object, "spec", "finalizers")
+
+	_, err = ri.Update(ctx, ns, metav1.UpdateOptions{}, "finalize")
+
+	return err
+}
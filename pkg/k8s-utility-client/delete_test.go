@@ -0,0 +1,766 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr/funcr"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+	"time"
+)
+
+// recordedDelete  The name and options of a single Delete call.
+type recordedDelete struct {
+	name string
+	opts metav1.DeleteOptions
+}
+
+// deleteRecorder  Collects Delete calls made through any number of recordingResourceInterfaces, in the order they were made.
+type deleteRecorder struct {
+	deletes []recordedDelete
+}
+
+// recordingResourceInterface  Wraps a ResourceInterface, recording every Delete call before passing it through.
+type recordingResourceInterface struct {
+	dynamic.ResourceInterface
+	recorder *deleteRecorder
+}
+
+func (r recordingResourceInterface) Delete(ctx context.Context, name string, opts metav1.DeleteOptions, subresources ...string) error {
+	r.recorder.deletes = append(r.recorder.deletes, recordedDelete{name: name, opts: opts})
+
+	return r.ResourceInterface.Delete(ctx, name, opts, subresources...)
+}
+
+// dryRunResourceInterface  Wraps a ResourceInterface, skipping any Delete sent with DryRun=All, like the API server does.  The fake dynamic client ignores DryRun altogether.
+type dryRunResourceInterface struct {
+	dynamic.ResourceInterface
+}
+
+func (r dryRunResourceInterface) Delete(ctx context.Context, name string, opts metav1.DeleteOptions, subresources ...string) error {
+	if len(opts.DryRun) > 0 && opts.DryRun[0] == metav1.DryRunAll {
+		return nil
+	}
+
+	return r.ResourceInterface.Delete(ctx, name, opts, subresources...)
+}
+
+func TestDeleteResourcesIgnoreNotFound(t *testing.T) {
+	testCases := []struct {
+		name           string
+		ignoreNotFound bool
+		errors         bool
+	}{
+		{
+			"not found is an error",
+			false,
+			true,
+		},
+		{
+			"not found is ignored",
+			true,
+			false,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("present", "default"))
+
+			client := &K8sClients{DynamicClient: dc}
+			ri := dc.Resource(gvr).Namespace("default")
+			interfaces := []dynamic.ResourceInterface{ri, ri}
+			objects := []*unstructured.Unstructured{testConfigMap("absent", "default"), testConfigMap("present", "default")}
+
+			err := client.DeleteResourcesWithOptions(context.TODO(), interfaces, objects, DeleteOptions{IgnoreNotFound: tc.ignoreNotFound})
+			if tc.errors {
+				assert.Error(t, err, "Expected an error deleting a nonexistent object.")
+				return
+			}
+
+			assert.NoError(t, err, "Deleting a nonexistent object should not error.")
+
+			_, err = ri.Get(context.TODO(), "present", metav1.GetOptions{})
+			assert.True(t, apierrors.IsNotFound(err), "Objects after the missing one were not deleted.")
+		})
+	}
+}
+
+func TestDeleteResourcesPropagationPolicy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		policy   metav1.DeletionPropagation
+		expected metav1.DeletionPropagation
+	}{
+		{
+			"default",
+			"",
+			metav1.DeletePropagationForeground,
+		},
+		{
+			"foreground",
+			metav1.DeletePropagationForeground,
+			metav1.DeletePropagationForeground,
+		},
+		{
+			"background",
+			metav1.DeletePropagationBackground,
+			metav1.DeletePropagationBackground,
+		},
+		{
+			"orphan",
+			metav1.DeletePropagationOrphan,
+			metav1.DeletePropagationOrphan,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("doomed", "default"))
+			recorder := &deleteRecorder{}
+
+			client := &K8sClients{DynamicClient: dc}
+			interfaces := []dynamic.ResourceInterface{recordingResourceInterface{dc.Resource(gvr).Namespace("default"), recorder}}
+			objects := []*unstructured.Unstructured{testConfigMap("doomed", "default")}
+
+			err := client.DeleteResourcesWithOptions(context.TODO(), interfaces, objects, DeleteOptions{PropagationPolicy: tc.policy})
+			if err != nil {
+				t.Fatalf("failed deleting resources: %s", err)
+			}
+
+			if assert.Len(t, recorder.deletes, 1, "Unexpected number of deletes.") {
+				if assert.NotNil(t, recorder.deletes[0].opts.PropagationPolicy, "No propagation policy sent.") {
+					assert.Equal(t, tc.expected, *recorder.deletes[0].opts.PropagationPolicy, "Propagation policy was not passed through.")
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteResourcesGracePeriod(t *testing.T) {
+	zero := int64(0)
+	thirty := int64(30)
+
+	testCases := []struct {
+		name        string
+		gracePeriod *int64
+	}{
+		{
+			"server default",
+			nil,
+		},
+		{
+			"immediate",
+			&zero,
+		},
+		{
+			"thirty seconds",
+			&thirty,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("doomed", "default"))
+			recorder := &deleteRecorder{}
+
+			client := &K8sClients{DynamicClient: dc}
+			interfaces := []dynamic.ResourceInterface{recordingResourceInterface{dc.Resource(gvr).Namespace("default"), recorder}}
+			objects := []*unstructured.Unstructured{testConfigMap("doomed", "default")}
+
+			err := client.DeleteResourcesWithOptions(context.TODO(), interfaces, objects, DeleteOptions{GracePeriodSeconds: tc.gracePeriod})
+			if err != nil {
+				t.Fatalf("failed deleting resources: %s", err)
+			}
+
+			if assert.Len(t, recorder.deletes, 1, "Unexpected number of deletes.") {
+				assert.Equal(t, tc.gracePeriod, recorder.deletes[0].opts.GracePeriodSeconds, "Grace period was not passed through.")
+			}
+		})
+	}
+}
+
+func TestDeleteResourcesReverseOrder(t *testing.T) {
+	testCases := []struct {
+		name     string
+		names    []string
+		expected []string
+	}{
+		{
+			"single",
+			[]string{"one"},
+			[]string{"one"},
+		},
+		{
+			"ordered",
+			[]string{"config", "secret", "app"},
+			[]string{"app", "secret", "config"},
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			existing := make([]runtime.Object, 0)
+			objects := make([]*unstructured.Unstructured, 0)
+			for _, name := range tc.names {
+				existing = append(existing, testConfigMap(name, "default"))
+				objects = append(objects, testConfigMap(name, "default"))
+			}
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), existing...)
+			recorder := &deleteRecorder{}
+
+			client := &K8sClients{DynamicClient: dc}
+			interfaces := make([]dynamic.ResourceInterface, 0)
+			for range objects {
+				interfaces = append(interfaces, recordingResourceInterface{dc.Resource(gvr).Namespace("default"), recorder})
+			}
+
+			err := client.DeleteResources(context.TODO(), interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed deleting resources: %s", err)
+			}
+
+			actual := make([]string, 0)
+			for _, d := range recorder.deletes {
+				actual = append(actual, d.name)
+			}
+
+			assert.Equal(t, tc.expected, actual, "Resources were not deleted in reverse order.")
+		})
+	}
+}
+
+func TestDeleteResourcesCancelled(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("one", "default"), testConfigMap("two", "default"))
+	recorder := &deleteRecorder{}
+
+	client := &K8sClients{DynamicClient: dc}
+	interfaces := []dynamic.ResourceInterface{
+		recordingResourceInterface{dc.Resource(gvr).Namespace("default"), recorder},
+		recordingResourceInterface{dc.Resource(gvr).Namespace("default"), recorder},
+	}
+	objects := []*unstructured.Unstructured{testConfigMap("one", "default"), testConfigMap("two", "default")}
+
+	err := client.DeleteResources(ctx, interfaces, objects)
+	assert.Equal(t, context.Canceled, errors.Cause(err), "Expected the context's error.  Got: %s", err)
+	assert.Empty(t, recorder.deletes, "Delete carried on after the context was cancelled.")
+}
+
+func TestDeleteResourcesLogging(t *testing.T) {
+	testCases := []struct {
+		name     string
+		logger   bool
+		expected []string
+	}{
+		{
+			"logger set",
+			true,
+			[]string{
+				`"level"=0 "msg"="Deleting" "kind"="ConfigMap" "name"="two" "namespace"="default"`,
+				`"level"=0 "msg"="Deleting" "kind"="ConfigMap" "name"="one" "namespace"="default"`,
+			},
+		},
+		{
+			"no logger",
+			false,
+			[]string{},
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("one", "default"), testConfigMap("two", "default"))
+
+			client := &K8sClients{DynamicClient: dc}
+
+			actual := make([]string, 0)
+			if tc.logger {
+				client.SetLogger(funcr.New(func(prefix, args string) {
+					actual = append(actual, args)
+				}, funcr.Options{}))
+			}
+
+			ri := dc.Resource(gvr).Namespace("default")
+			interfaces := []dynamic.ResourceInterface{ri, ri}
+			objects := []*unstructured.Unstructured{testConfigMap("one", "default"), testConfigMap("two", "default")}
+
+			err := client.DeleteResources(context.TODO(), interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed deleting resources: %s", err)
+			}
+
+			assert.Equal(t, tc.expected, actual, "Log lines do not match expectations.")
+		})
+	}
+}
+
+func TestDeleteObject(t *testing.T) {
+	testCases := []struct {
+		name   string
+		exists bool
+		errors bool
+	}{
+		{
+			"existing",
+			true,
+			false,
+		},
+		{
+			"missing",
+			false,
+			true,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			if tc.exists {
+				_, err := client.ApplyObject(context.TODO(), testConfigMap("single", "default"))
+				if err != nil {
+					t.Fatalf("failed creating object: %s", err)
+				}
+			}
+
+			err := client.DeleteObject(context.TODO(), testConfigMap("single", "default"), metav1.DeleteOptions{})
+			if tc.errors {
+				assert.True(t, IsNotFound(err), "Expected a NotFound error deleting a missing object.  Got: %s", err)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed deleting object: %s", err)
+			}
+
+			_, err = dc.Resource(gvr).Namespace("default").Get(context.TODO(), "single", metav1.GetOptions{})
+			assert.True(t, apierrors.IsNotFound(err), "Object still exists after deletion.")
+		})
+	}
+}
+
+func TestDeleteResourcesContinueOnError(t *testing.T) {
+	testCases := []struct {
+		name            string
+		continueOnError bool
+		remaining       []string
+	}{
+		{
+			"fail fast",
+			false,
+			[]string{"present-one", "present-two"},
+		},
+		{
+			"continue on error",
+			true,
+			[]string{},
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "ConfigMapList"}, testConfigMap("present-one", "default"), testConfigMap("present-two", "default"))
+
+			client := &K8sClients{DynamicClient: dc}
+			ri := dc.Resource(gvr).Namespace("default")
+			interfaces := []dynamic.ResourceInterface{ri, ri, ri, ri}
+			// deleted in reverse order, so absent-two fails first
+			objects := []*unstructured.Unstructured{
+				testConfigMap("present-one", "default"),
+				testConfigMap("absent-one", "default"),
+				testConfigMap("present-two", "default"),
+				testConfigMap("absent-two", "default"),
+			}
+
+			err := client.DeleteResourcesWithOptions(context.TODO(), interfaces, objects, DeleteOptions{ContinueOnError: tc.continueOnError})
+			if !assert.Error(t, err, "Expected an error deleting missing objects.") {
+				return
+			}
+
+			if tc.continueOnError {
+				var aggregate utilerrors.Aggregate
+				if assert.True(t, errors.As(err, &aggregate), "Expected an aggregate error.  Got: %s", err) {
+					assert.Equal(t, 2, len(aggregate.Errors()), "Wrong number of errors reported.")
+				}
+
+				assert.Contains(t, err.Error(), "absent-one", "First failure not reported.")
+				assert.Contains(t, err.Error(), "absent-two", "Second failure not reported.")
+			}
+
+			list, err := ri.List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed listing configmaps: %s", err)
+			}
+
+			remaining := make([]string, 0)
+			for _, o := range list.Items {
+				remaining = append(remaining, o.GetName())
+			}
+
+			assert.ElementsMatch(t, tc.remaining, remaining, "Remaining objects do not match expectations.")
+		})
+	}
+}
+
+func TestDeleteFromFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+		errors   bool
+	}{
+		{
+			"configmap",
+			"test_fixtures/configmap.yaml",
+			false,
+		},
+		{
+			"missing file",
+			"test_fixtures/no-such-file.yaml",
+			true,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			err := client.ApplyFromFile(context.TODO(), "test_fixtures/configmap.yaml", ApplyOptions{})
+			if err != nil {
+				t.Fatalf("failed applying from file: %s", err)
+			}
+
+			err = client.DeleteFromFile(context.TODO(), tc.fileName, DeleteOptions{})
+			if tc.errors {
+				assert.Error(t, err, "Expected an error deleting from a missing file.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed deleting from file: %s", err)
+			}
+
+			_, err = dc.Resource(gvr).Namespace("default").Get(context.TODO(), "utility-client-test", metav1.GetOptions{})
+			assert.True(t, IsNotFound(err), "ConfigMap from file was not deleted.")
+		})
+	}
+}
+
+func TestDeleteResourcesDryRunOption(t *testing.T) {
+	testCases := []struct {
+		name    string
+		dryRun  bool
+		remains bool
+	}{
+		{
+			"delete",
+			false,
+			false,
+		},
+		{
+			"dry run",
+			true,
+			true,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("doomed", "default"))
+
+			recorder := &deleteRecorder{}
+
+			client := &K8sClients{DynamicClient: dc}
+			interfaces := []dynamic.ResourceInterface{recordingResourceInterface{dryRunResourceInterface{dc.Resource(gvr).Namespace("default")}, recorder}}
+			objects := []*unstructured.Unstructured{testConfigMap("doomed", "default")}
+
+			err := client.DeleteResourcesWithOptions(context.TODO(), interfaces, objects, DeleteOptions{DryRun: tc.dryRun})
+			if err != nil {
+				t.Fatalf("failed deleting resources: %s", err)
+			}
+
+			if assert.Len(t, recorder.deletes, 1, "Unexpected number of deletes.") {
+				assert.Equal(t, tc.dryRun, len(recorder.deletes[0].opts.DryRun) > 0, "DryRun was not passed through.")
+			}
+
+			_, err = dc.Resource(gvr).Namespace("default").Get(context.TODO(), "doomed", metav1.GetOptions{})
+			if tc.remains {
+				assert.NoError(t, err, "Object is gone after a dry-run delete.")
+				return
+			}
+
+			assert.True(t, IsNotFound(err), "Object was not deleted.")
+		})
+	}
+}
+
+func TestDeleteResourcesDryRun(t *testing.T) {
+	client, err := NewK8sClients()
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	interfaces, objects, err := client.ResourcesAndObjectsFromFile("test_fixtures/configmap.yaml")
+	if err != nil {
+		t.Fatalf("failed to load yaml file: %s", err)
+	}
+
+	ctx := context.TODO()
+
+	err = client.ApplyResources(ctx, interfaces, objects)
+	if err != nil {
+		t.Fatalf("failed to apply resources: %s", err)
+	}
+
+	defer func() {
+		err = client.DeleteResources(ctx, interfaces, objects)
+		if err != nil {
+			t.Errorf("failed deleting resources: %s", err)
+		}
+	}()
+
+	fmt.Printf("Dry-run deleting resources in k8s.\n")
+	err = client.DeleteResourcesWithOptions(ctx, interfaces, objects, DeleteOptions{DryRun: true})
+	if err != nil {
+		t.Errorf("failed to dry-run delete resources: %s", err)
+	}
+
+	for i, obj := range objects {
+		_, err := interfaces[i].Get(ctx, obj.GetName(), metav1.GetOptions{})
+		assert.NoError(t, err, "Resource %s kind %s is gone after a dry-run delete.", obj.GetName(), obj.GetKind())
+	}
+}
+
+func TestForceDelete(t *testing.T) {
+	testCases := []struct {
+		name       string
+		finalizers []string
+		exists     bool
+		patches    int
+	}{
+		{
+			"no finalizers",
+			nil,
+			true,
+			0,
+		},
+		{
+			"stuck on a finalizer",
+			[]string{"utility-client.example.com/dummy"},
+			true,
+			1,
+		},
+		{
+			"already gone",
+			nil,
+			false,
+			0,
+		},
+	}
+
+	defer func(grace time.Duration) { forceDeleteGrace = grace }(forceDeleteGrace)
+	forceDeleteGrace = 100 * time.Millisecond
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := make([]runtime.Object, 0)
+			if tc.exists {
+				obj := testConfigMap("stuck", "default")
+				obj.SetFinalizers(tc.finalizers)
+				objects = append(objects, obj)
+			}
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+
+			// the fake removes things outright, so play the part of the server and leave finalized objects Terminating
+			dc.PrependReactor("delete", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				existing, err := dc.Tracker().Get(gvr, "default", "stuck")
+				if err != nil {
+					return false, nil, nil
+				}
+
+				obj := existing.(*unstructured.Unstructured)
+				if len(obj.GetFinalizers()) == 0 {
+					return false, nil, nil
+				}
+
+				now := metav1.Now()
+				obj.SetDeletionTimestamp(&now)
+				err = dc.Tracker().Update(gvr, obj, "default")
+
+				return true, nil, err
+			})
+
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			err := client.ForceDelete(context.TODO(), gvk, "default", "stuck")
+			if err != nil {
+				t.Fatalf("failed force deleting: %s", err)
+			}
+
+			patches := 0
+			for _, action := range dc.Actions() {
+				if action.GetVerb() == "patch" {
+					patches++
+				}
+			}
+
+			assert.Equal(t, tc.patches, patches, "Unexpected number of patches.")
+
+			obj, err := dc.Resource(gvr).Namespace("default").Get(context.TODO(), "stuck", metav1.GetOptions{})
+			if err != nil {
+				assert.True(t, apierrors.IsNotFound(err), "Unexpected error getting force deleted object: %s", err)
+				return
+			}
+
+			// with the finalizers gone, a real server finishes the delete
+			assert.Empty(t, obj.GetFinalizers(), "Finalizers should have been removed.")
+			assert.NotNil(t, obj.GetDeletionTimestamp(), "Object should still be marked for deletion.")
+		})
+	}
+}
+
+func TestForceDeleteNamespace(t *testing.T) {
+	testCases := []struct {
+		name           string
+		finalizers     []string
+		specFinalizers []interface{}
+		finalizes      int
+	}{
+		{
+			"held by spec finalizers",
+			nil,
+			[]interface{}{"kubernetes"},
+			1,
+		},
+		{
+			"held by both",
+			[]string{"utility-client.example.com/dummy"},
+			[]interface{}{"kubernetes"},
+			1,
+		},
+		{
+			"held by metadata finalizers",
+			[]string{"utility-client.example.com/dummy"},
+			nil,
+			0,
+		},
+	}
+
+	defer func(grace time.Duration) { forceDeleteGrace = grace }(forceDeleteGrace)
+	forceDeleteGrace = 100 * time.Millisecond
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Namespace",
+				"metadata": map[string]interface{}{
+					"name": "stuck",
+				},
+			}}
+			ns.SetFinalizers(tc.finalizers)
+			if tc.specFinalizers != nil {
+				_ = unstructured.SetNestedSlice(ns.Object, tc.specFinalizers, "spec", "finalizers")
+			}
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), ns)
+
+			// the fake removes things outright, so play the part of the server and leave finalized namespaces Terminating
+			dc.PrependReactor("delete", "namespaces", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				existing, err := dc.Tracker().Get(gvr, "", "stuck")
+				if err != nil {
+					return false, nil, nil
+				}
+
+				obj := existing.(*unstructured.Unstructured)
+				specFinalizers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "finalizers")
+				if len(obj.GetFinalizers()) == 0 && len(specFinalizers) == 0 {
+					return false, nil, nil
+				}
+
+				now := metav1.Now()
+				obj.SetDeletionTimestamp(&now)
+				err = dc.Tracker().Update(gvr, obj, "")
+
+				return true, nil, err
+			})
+
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			err := client.ForceDelete(context.TODO(), gvk, "", "stuck")
+			if err != nil {
+				t.Fatalf("failed force deleting: %s", err)
+			}
+
+			finalizes := 0
+			for _, action := range dc.Actions() {
+				if action.GetVerb() == "update" && action.GetSubresource() == "finalize" {
+					finalizes++
+				}
+			}
+
+			assert.Equal(t, tc.finalizes, finalizes, "Unexpected number of finalize calls.")
+
+			obj, err := dc.Resource(gvr).Get(context.TODO(), "stuck", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed getting namespace: %s", err)
+			}
+
+			// with the finalizers gone, a real server finishes the delete
+			specFinalizers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "finalizers")
+			assert.Empty(t, specFinalizers, "Spec finalizers should have been removed.")
+			assert.Empty(t, obj.GetFinalizers(), "Finalizers should have been removed.")
+		})
+	}
+}
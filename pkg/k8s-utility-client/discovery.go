@@ -0,0 +1,107 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/disk"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DEFAULT_DISCOVERY_CACHE_TTL  How long discovery data cached on disk is trusted, when ClientOptions doesn't say.  The same as kubectl.
+const DEFAULT_DISCOVERY_CACHE_TTL = 6 * time.Hour
+
+// unsafeCacheDirCharacters  Anything in an API server's host that shouldn't end up in a directory name.  The same as kubectl uses, so the two can share a cache.
+var unsafeCacheDirCharacters = regexp.MustCompile(`[^(\w/.)]`)
+
+// Discovery  Returns a client for discovering what the API server supports: its version, API groups, resources, and OpenAPI schema.  Groups and resources are cached in memory, or on disk if ClientOptions has a DiscoveryCacheDir, so repeated lookups don't go back to the server.  The cache is shared with the RESTMapper.  Returns nil if the K8sClients has no ClientSet.
+func (k *K8sClients) Discovery() discovery.DiscoveryInterface {
+	d := k.cachedDiscovery()
+	if d == nil {
+		return nil
+	}
+
+	return d
+}
+
+// cachedDiscovery  Returns the cached discovery client.  Unless ClientOptions asked for a disk cache, it's an in-memory one, built on first use.
+func (k *K8sClients) cachedDiscovery() discovery.CachedDiscoveryInterface {
+	if k.discoveryClient == nil && k.ClientSet != nil {
+		k.discoveryClient = memory.NewMemCacheClient(k.ClientSet.Discovery())
+	}
+
+	return k.discoveryClient
+}
+
+// diskCachedDiscovery  Builds a discovery client that caches on disk under cacheDir, laid out like kubectl's ~/.kube/cache.  Discovery documents go under discovery/<host>, and are trusted for ttl.  Everything else, including the OpenAPI schema, goes under http/, and is revalidated with the server using ETags.
+func diskCachedDiscovery(config *rest.Config, cacheDir string, ttl time.Duration) (d discovery.CachedDiscoveryInterface, err error) {
+	if ttl <= 0 {
+		ttl = DEFAULT_DISCOVERY_CACHE_TTL
+	}
+
+	host := strings.Replace(strings.Replace(config.Host, "https://", "", 1), "http://", "", 1)
+	discoveryCacheDir := filepath.Join(cacheDir, "discovery", unsafeCacheDirCharacters.ReplaceAllString(host, "_"))
+	httpCacheDir := filepath.Join(cacheDir, "http")
+
+	d, err = disk.NewCachedDiscoveryClientForConfig(config, discoveryCacheDir, httpCacheDir, ttl)
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating discovery client cached in %s", cacheDir)
+		return d, err
+	}
+
+	return d, err
+}
+
+// ServerVersion  Returns the version of the API server.  Compare Major and Minor to branch on what the cluster supports, e.g. whether an API has gone GA.
+func (k *K8sClients) ServerVersion() (info *version.Info, err error) {
+	d := k.Discovery()
+	if d == nil {
+		err = errors.New("no k8s clientset from which to get the server version")
+		return info, err
+	}
+
+	info, err = d.ServerVersion()
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting server version")
+		return info, err
+	}
+
+	return info, err
+}
+
+// APIResources  Lists the resources the server supports, at the preferred version of each API group.  Handy for feature detection, e.g. whether a CRD is installed, or which kinds can be listed.  If some API groups can't be discovered, often an aggregated API whose backing service is down, the rest are still returned, together with a *discovery.ErrGroupDiscoveryFailed naming the groups that failed.  Check for it with discovery.IsGroupDiscoveryFailedError() to tell a partial list from a failure, and treat anything in the failed groups as unknown rather than absent.
+func (k *K8sClients) APIResources() (resources []*metav1.APIResourceList, err error) {
+	d := k.Discovery()
+	if d == nil {
+		err = errors.New("no k8s clientset from which to discover resources")
+		return resources, err
+	}
+
+	resources, err = d.ServerPreferredResources()
+	if err != nil {
+		// left unwrapped, so callers can still recognise it
+		if discovery.IsGroupDiscoveryFailedError(err) {
+			return resources, err
+		}
+
+		err = errors.Wrapf(err, "failed discovering api resources")
+		return resources, err
+	}
+
+	return resources, err
+}
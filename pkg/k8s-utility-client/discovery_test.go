@@ -0,0 +1,246 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestServerVersion(t *testing.T) {
+	client, err := NewK8sClients()
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	info, err := client.ServerVersion()
+	if err != nil {
+		t.Fatalf("failed getting server version: %s", err)
+	}
+
+	assert.NotEmpty(t, info.Major, "Server major version is empty.")
+	assert.NotEmpty(t, info.Minor, "Server minor version is empty.")
+	assert.NotEmpty(t, info.GitVersion, "Server git version is empty.")
+}
+
+func TestDiscovery(t *testing.T) {
+	client, err := NewK8sClients()
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	groups, err := client.Discovery().ServerGroups()
+	if err != nil {
+		t.Fatalf("failed listing server groups: %s", err)
+	}
+
+	names := make([]string, 0)
+	for _, group := range groups.Groups {
+		names = append(names, group.Name)
+	}
+
+	// the legacy core group has no name
+	assert.Contains(t, names, "", "Core API group not found.")
+	assert.Contains(t, names, "apps", "apps API group not found.")
+}
+
+func TestDiscoveryWithoutClientSet(t *testing.T) {
+	client := &K8sClients{}
+
+	assert.Nil(t, client.Discovery(), "Expected no discovery client without a clientset.")
+}
+
+func TestAPIResources(t *testing.T) {
+	testCases := []struct {
+		name         string
+		groupVersion string
+		resource     string
+	}{
+		{
+			"pods",
+			"v1",
+			"pods",
+		},
+		{
+			"deployments",
+			"apps/v1",
+			"deployments",
+		},
+	}
+
+	client, err := NewK8sClients()
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	resources, err := client.APIResources()
+	if err != nil {
+		t.Fatalf("failed listing api resources: %s", err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			names := make([]string, 0)
+			for _, list := range resources {
+				if list.GroupVersion == tc.groupVersion {
+					for _, resource := range list.APIResources {
+						names = append(names, resource.Name)
+					}
+				}
+			}
+
+			assert.Contains(t, names, tc.resource, "Resource not found in %s.", tc.groupVersion)
+		})
+	}
+}
+
+// partialDiscovery  A discovery client that can't reach one API group, like a cluster whose metrics-server is down.
+type partialDiscovery struct {
+	discovery.CachedDiscoveryInterface
+}
+
+func (d *partialDiscovery) ServerPreferredResources() (resources []*metav1.APIResourceList, err error) {
+	resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "v1",
+			APIResources: []metav1.APIResource{{Name: "pods", Kind: "Pod", Namespaced: true}},
+		},
+	}
+
+	err = &discovery.ErrGroupDiscoveryFailed{
+		Groups: map[schema.GroupVersion]error{
+			{Group: "metrics.k8s.io", Version: "v1beta1"}: errors.New("the server is currently unable to handle the request"),
+		},
+	}
+
+	return resources, err
+}
+
+func TestAPIResourcesPartial(t *testing.T) {
+	client := &K8sClients{discoveryClient: &partialDiscovery{}}
+
+	resources, err := client.APIResources()
+	if assert.Error(t, err, "Expected an error saying which groups could not be discovered.") {
+		assert.True(t, discovery.IsGroupDiscoveryFailedError(err), "Expected a group discovery failure, got: %s", err)
+		assert.Contains(t, err.Error(), "metrics.k8s.io", "Error does not name the failed group.")
+	}
+
+	if assert.Equal(t, 1, len(resources), "Expected the groups that were discovered.") {
+		assert.Equal(t, "v1", resources[0].GroupVersion, "Unexpected group discovered.")
+	}
+}
+
+func TestServerVersionWithoutClientSet(t *testing.T) {
+	// like the clients the fake tests build, with only a dynamic client
+	client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())}
+
+	_, err := client.ServerVersion()
+	assert.Error(t, err, "Expected an error getting the server version without a clientset.")
+}
+
+func TestAPIResourcesWithoutClientSet(t *testing.T) {
+	client := &K8sClients{}
+
+	_, err := client.APIResources()
+	assert.Error(t, err, "Expected an error discovering resources without a clientset.")
+}
+
+// testKubeconfig  Points KUBECONFIG at a temp file for a cluster at serverURL, such as an httptest server, for the rest of the test.
+func testKubeconfig(t *testing.T, serverURL string) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	err := os.WriteFile(kubeconfig, []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+users:
+- name: test
+  user: {}
+`, serverURL)), 0600)
+	if err != nil {
+		t.Fatalf("failed writing temp kubeconfig: %s", err)
+	}
+
+	t.Setenv("KUBECONFIG", kubeconfig)
+}
+
+func TestDiscoveryCacheDir(t *testing.T) {
+	var resourceRequests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/api":
+			_, _ = w.Write([]byte(`{"kind": "APIVersions", "versions": ["v1"]}`))
+		case "/apis":
+			_, _ = w.Write([]byte(`{"kind": "APIGroupList", "apiVersion": "v1", "groups": []}`))
+		case "/api/v1":
+			atomic.AddInt32(&resourceRequests, 1)
+			_, _ = w.Write([]byte(`{"kind": "APIResourceList", "groupVersion": "v1", "resources": [{"name": "configmaps", "namespaced": true, "kind": "ConfigMap", "verbs": ["get", "list"]}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	testKubeconfig(t, server.URL)
+
+	cacheDir := t.TempDir()
+	opts := ClientOptions{ClusterMode: CLUSTER_MODE_OUT_OF_CLUSTER, DiscoveryCacheDir: cacheDir}
+
+	// the first client discovers from the server, and fills the cache
+	client, err := NewK8sClientsWithOptions(opts)
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	_, err = client.APIResources()
+	if err != nil {
+		t.Fatalf("failed discovering api resources: %s", err)
+	}
+
+	hostDir := strings.Replace(strings.TrimPrefix(server.URL, "http://"), ":", "_", 1)
+	assert.FileExists(t, filepath.Join(cacheDir, "discovery", hostDir, "servergroups.json"), "Server groups were not cached.")
+	assert.FileExists(t, filepath.Join(cacheDir, "discovery", hostDir, "v1", "serverresources.json"), "Server resources were not cached.")
+
+	// a second client, as from the next run of a CLI, reads from the cache instead
+	client, err = NewK8sClientsWithOptions(opts)
+	if err != nil {
+		t.Fatalf("failed creating second client: %s", err)
+	}
+
+	_, err = client.APIResources()
+	if err != nil {
+		t.Fatalf("failed discovering api resources from the cache: %s", err)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&resourceRequests), "Resources should have been discovered from the server only once.")
+}
@@ -0,0 +1,45 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IsNotFound  Reports whether err, or the error it wraps, says the resource doesn't exist.  Saves callers importing apierrors just to classify errors returned by this package.
+func IsNotFound(err error) bool {
+	return apierrors.IsNotFound(errors.Cause(err))
+}
+
+// IsConflict  Reports whether err, or the error it wraps, is an optimistic lock conflict, i.e. someone else modified the resource first.
+func IsConflict(err error) bool {
+	return apierrors.IsConflict(errors.Cause(err))
+}
+
+// IsAlreadyExists  Reports whether err, or the error it wraps, says the resource already exists.
+func IsAlreadyExists(err error) bool {
+	return apierrors.IsAlreadyExists(errors.Cause(err))
+}
+
+// StatusCode  Returns the HTTP status code the API server sent with err, or the error it wraps, so callers can branch on 404 vs 409 vs 403.  Returns 0 if err didn't come from the API server.
+func StatusCode(err error) int32 {
+	if status, ok := errors.Cause(err).(apierrors.APIStatus); ok {
+		return status.Status().Code
+	}
+
+	return 0
+}
+
+// StatusReason  Returns the machine readable reason the API server gave for err, or the error it wraps.  Returns metav1.StatusReasonUnknown if err didn't come from the API server.
+func StatusReason(err error) metav1.StatusReason {
+	return apierrors.ReasonForError(errors.Cause(err))
+}
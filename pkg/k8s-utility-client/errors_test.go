@@ -0,0 +1,130 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"testing"
+)
+
+func TestErrorHelpers(t *testing.T) {
+	gr := schema.GroupResource{Resource: "configmaps"}
+
+	notFound := apierrors.NewNotFound(gr, "foo")
+	conflict := apierrors.NewConflict(gr, "foo", fmt.Errorf("the object has been modified"))
+	alreadyExists := apierrors.NewAlreadyExists(gr, "foo")
+
+	testCases := []struct {
+		name          string
+		err           error
+		notFound      bool
+		conflict      bool
+		alreadyExists bool
+	}{
+		{
+			"nil",
+			nil,
+			false,
+			false,
+			false,
+		},
+		{
+			"plain error",
+			errors.New("something else"),
+			false,
+			false,
+			false,
+		},
+		{
+			"not found",
+			notFound,
+			true,
+			false,
+			false,
+		},
+		{
+			"wrapped not found",
+			errors.Wrapf(notFound, "failed getting foo kind ConfigMap"),
+			true,
+			false,
+			false,
+		},
+		{
+			"doubly wrapped conflict",
+			errors.Wrapf(errors.Wrapf(conflict, "failed updating foo kind ConfigMap"), "failed applying"),
+			false,
+			true,
+			false,
+		},
+		{
+			"wrapped already exists",
+			errors.Wrapf(alreadyExists, "failed creating foo kind ConfigMap"),
+			false,
+			false,
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.notFound, IsNotFound(tc.err), "IsNotFound misclassified the error.")
+			assert.Equal(t, tc.conflict, IsConflict(tc.err), "IsConflict misclassified the error.")
+			assert.Equal(t, tc.alreadyExists, IsAlreadyExists(tc.err), "IsAlreadyExists misclassified the error.")
+		})
+	}
+}
+
+func TestStatusCodeAndReason(t *testing.T) {
+	gr := schema.GroupResource{Resource: "configmaps"}
+
+	testCases := []struct {
+		name   string
+		err    error
+		code   int32
+		reason metav1.StatusReason
+	}{
+		{
+			"plain error",
+			errors.New("something else"),
+			0,
+			metav1.StatusReasonUnknown,
+		},
+		{
+			"wrapped not found",
+			errors.Wrapf(apierrors.NewNotFound(gr, "foo"), "failed getting foo kind ConfigMap"),
+			404,
+			metav1.StatusReasonNotFound,
+		},
+		{
+			"wrapped conflict",
+			errors.Wrapf(apierrors.NewConflict(gr, "foo", fmt.Errorf("the object has been modified")), "failed updating foo kind ConfigMap"),
+			409,
+			metav1.StatusReasonConflict,
+		},
+		{
+			"wrapped forbidden",
+			errors.Wrapf(apierrors.NewForbidden(gr, "foo", fmt.Errorf("no access")), "failed creating foo kind ConfigMap"),
+			403,
+			metav1.StatusReasonForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.code, StatusCode(tc.err), "Unexpected status code.")
+			assert.Equal(t, tc.reason, StatusReason(tc.err), "Unexpected status reason.")
+		})
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"testing"
+	"time"
+)
+
+func TestResourceEvents(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+		reasons  []string
+	}{
+		{
+			"bad image",
+			"test_fixtures/bad-image-pod.yaml",
+			[]string{"Failed", "ErrImagePull", "BackOff"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Creating resources in k8s.\n")
+			err = client.ApplyResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed to apply resources: %s", err)
+			}
+
+			defer func() {
+				fmt.Printf("Cleaning up resources in k8s.\n")
+				err = client.DeleteResources(ctx, interfaces, objects)
+				if err != nil {
+					t.Errorf("failed deleting resources: %s", err)
+				}
+			}()
+
+			pod := objects[0]
+			found := ""
+
+			// the kubelet takes a moment to fail the pull
+			err = wait.PollImmediateWithContext(ctx, POLL_INTERVAL, 2*time.Minute, func(ctx context.Context) (done bool, err error) {
+				events, err := client.ResourceEvents(ctx, pod.GetNamespace(), pod.GetName())
+				if err != nil {
+					return false, err
+				}
+
+				for _, event := range events {
+					assert.Equal(t, pod.GetName(), event.InvolvedObject.Name, "Event for another object returned.")
+
+					for _, reason := range tc.reasons {
+						if event.Reason == reason {
+							found = reason
+							return true, nil
+						}
+					}
+				}
+
+				return false, nil
+			})
+
+			assert.NoError(t, err, "No failure event was returned for pod %s.", pod.GetName())
+			assert.NotEmpty(t, found, "No failure event was returned for pod %s.", pod.GetName())
+		})
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
+)
+
+// FilterByKind  Returns just the interfaces and objects of the given kinds, e.g. to apply only the ConfigMaps from a manifest.  Kinds are matched exactly, and case-sensitively, as in the objects' kind field.  The returned slices stay index-aligned, in their original order.
+func FilterByKind(interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, kinds ...string) (filteredInterfaces []dynamic.ResourceInterface, filteredObjects []*unstructured.Unstructured) {
+	wanted := make(map[string]bool)
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+
+	return filterObjects(interfaces, objects, func(obj *unstructured.Unstructured) bool {
+		return wanted[obj.GetKind()]
+	})
+}
+
+// FilterByLabels  Returns just the interfaces and objects whose labels match the selector, e.g. the parts of a shared manifest your tool manages.  Build the selector with labels.Parse("app=web,tier!=backend") or labels.SelectorFromSet().  The returned slices stay index-aligned, in their original order.
+func FilterByLabels(interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, selector labels.Selector) (filteredInterfaces []dynamic.ResourceInterface, filteredObjects []*unstructured.Unstructured) {
+	return filterObjects(interfaces, objects, func(obj *unstructured.Unstructured) bool {
+		return selector.Matches(labels.Set(obj.GetLabels()))
+	})
+}
+
+// filterObjects  Returns the interfaces and objects for which keep returns true, still index-aligned, and in their original order.
+func filterObjects(interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, keep func(obj *unstructured.Unstructured) bool) (keptInterfaces []dynamic.ResourceInterface, keptObjects []*unstructured.Unstructured) {
+	keptInterfaces = make([]dynamic.ResourceInterface, 0)
+	keptObjects = make([]*unstructured.Unstructured, 0)
+
+	for i, obj := range objects {
+		if !keep(obj) {
+			continue
+		}
+
+		keptInterfaces = append(keptInterfaces, interfaces[i])
+		keptObjects = append(keptObjects, obj)
+	}
+
+	return keptInterfaces, keptObjects
+}
+
+// inNamespaces  Returns a filter that keeps objects in any of the namespaces, along with cluster-scoped objects, which aren't in any namespace.
+func inNamespaces(namespaces []string) func(obj *unstructured.Unstructured) bool {
+	allowed := make(map[string]bool)
+	for _, namespace := range namespaces {
+		allowed[namespace] = true
+	}
+
+	return func(obj *unstructured.Unstructured) bool {
+		return obj.GetNamespace() == "" || allowed[obj.GetNamespace()]
+	}
+}
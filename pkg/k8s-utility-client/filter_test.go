@@ -0,0 +1,145 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+// filterFixture  Loads the mixed manifest the filter tests pick through.
+func filterFixture(t *testing.T) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured) {
+	client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+	interfaces, objects, err := client.ResourcesAndObjectsFromFile("test_fixtures/mixed.yaml")
+	if err != nil {
+		t.Fatalf("failed loading mixed manifest: %s", err)
+	}
+
+	return interfaces, objects
+}
+
+// kindsAndNames  Describes the objects as Kind/name, for comparing.
+func kindsAndNames(objects []*unstructured.Unstructured) (names []string) {
+	names = make([]string, 0)
+	for _, obj := range objects {
+		names = append(names, fmt.Sprintf("%s/%s", obj.GetKind(), obj.GetName()))
+	}
+
+	return names
+}
+
+func TestFilterByKind(t *testing.T) {
+	testCases := []struct {
+		name     string
+		kinds    []string
+		expected []string
+	}{
+		{
+			"services",
+			[]string{"Service"},
+			[]string{"Service/web", "Service/db"},
+		},
+		{
+			"several kinds",
+			[]string{"ConfigMap", "Deployment"},
+			[]string{"Deployment/web", "ConfigMap/web-config"},
+		},
+		{
+			"case sensitive",
+			[]string{"service"},
+			[]string{},
+		},
+		{
+			"no kinds",
+			nil,
+			[]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			interfaces, objects := filterFixture(t)
+
+			filteredInterfaces, filteredObjects := FilterByKind(interfaces, objects, tc.kinds...)
+			assert.Equal(t, tc.expected, kindsAndNames(filteredObjects), "Unexpected objects kept.")
+			assert.Equal(t, len(filteredObjects), len(filteredInterfaces), "Interfaces and objects are no longer aligned.")
+
+			// each object is still paired with its own interface
+			for i, obj := range filteredObjects {
+				for j := range objects {
+					if objects[j] == obj {
+						assert.Equal(t, interfaces[j], filteredInterfaces[i], "Object %s lost its interface.", obj.GetName())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFilterByLabels(t *testing.T) {
+	testCases := []struct {
+		name     string
+		selector string
+		expected []string
+	}{
+		{
+			"equality",
+			"app=web",
+			[]string{"Deployment/web", "Service/web", "ConfigMap/web-config"},
+		},
+		{
+			"several requirements",
+			"app=web,tier=frontend",
+			[]string{"Deployment/web", "Service/web"},
+		},
+		{
+			"set based",
+			"tier in (backend)",
+			[]string{"Service/db"},
+		},
+		{
+			"label missing",
+			"!tier",
+			[]string{"ConfigMap/web-config"},
+		},
+		{
+			"everything",
+			"",
+			[]string{"Deployment/web", "Service/web", "ConfigMap/web-config", "Service/db"},
+		},
+		{
+			"nothing matches",
+			"app=cache",
+			[]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			selector, err := labels.Parse(tc.selector)
+			if err != nil {
+				t.Fatalf("failed parsing selector %q: %s", tc.selector, err)
+			}
+
+			interfaces, objects := filterFixture(t)
+
+			filteredInterfaces, filteredObjects := FilterByLabels(interfaces, objects, selector)
+			assert.Equal(t, tc.expected, kindsAndNames(filteredObjects), "Unexpected objects kept.")
+			assert.Equal(t, len(filteredObjects), len(filteredInterfaces), "Interfaces and objects are no longer aligned.")
+		})
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// serverManagedMetadata  Metadata fields the server sets, which say nothing about what the object's author asked for.
+var serverManagedMetadata = []string{
+	"resourceVersion",
+	"uid",
+	"generation",
+	"creationTimestamp",
+	"deletionTimestamp",
+	"deletionGracePeriodSeconds",
+	"managedFields",
+	"selfLink",
+}
+
+// ObjectHash  Returns a stable hash of an object's content, for telling whether two versions of an object differ.  Server-managed fields, such as resourceVersion, uid, and managedFields, and the whole status are left out, so an object read back from the cluster hashes the same as the one applied, so long as nothing filled in defaults.  The hash is the hex encoded SHA-256 of the remaining fields, as JSON with sorted keys.
+func ObjectHash(obj *unstructured.Unstructured) (hash string, err error) {
+	content := obj.DeepCopy()
+
+	for _, field := range serverManagedMetadata {
+		unstructured.RemoveNestedField(content.Object, "metadata", field)
+	}
+
+	unstructured.RemoveNestedField(content.Object, "status")
+
+	// encoding/json sorts map keys, so the same content always marshals the same way
+	b, err := json.Marshal(content.Object)
+	if err != nil {
+		err = errors.Wrapf(err, "failed marshalling %s kind %s for hashing", obj.GetName(), obj.GetKind())
+		return hash, err
+	}
+
+	sum := sha256.Sum256(b)
+	hash = hex.EncodeToString(sum[:])
+
+	return hash, err
+}
+
+// sameContent  Reports whether two objects have the same ObjectHash.
+func sameContent(a *unstructured.Unstructured, b *unstructured.Unstructured) (same bool, err error) {
+	aHash, err := ObjectHash(a)
+	if err != nil {
+		return same, err
+	}
+
+	bHash, err := ObjectHash(b)
+	if err != nil {
+		return same, err
+	}
+
+	return aHash == bHash, err
+}
@@ -0,0 +1,108 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"testing"
+	"time"
+)
+
+func TestObjectHash(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(obj *unstructured.Unstructured)
+		equal  bool
+	}{
+		{
+			"identical",
+			func(obj *unstructured.Unstructured) {},
+			true,
+		},
+		{
+			"resource version",
+			func(obj *unstructured.Unstructured) {
+				obj.SetResourceVersion("12345")
+			},
+			true,
+		},
+		{
+			"server managed metadata",
+			func(obj *unstructured.Unstructured) {
+				obj.SetUID(types.UID("d9607e19-f88f-11e6-a518-42010a800195"))
+				obj.SetGeneration(3)
+				obj.SetCreationTimestamp(metav1.NewTime(time.Now()))
+				obj.SetManagedFields([]metav1.ManagedFieldsEntry{{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply}})
+			},
+			true,
+		},
+		{
+			"status",
+			func(obj *unstructured.Unstructured) {
+				_ = unstructured.SetNestedField(obj.Object, "Active", "status", "phase")
+			},
+			true,
+		},
+		{
+			"data",
+			func(obj *unstructured.Unstructured) {
+				withData(obj, "baz")
+			},
+			false,
+		},
+		{
+			"labels",
+			func(obj *unstructured.Unstructured) {
+				obj.SetLabels(map[string]string{"app": "hashed"})
+			},
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := testConfigMap("hashed", "default")
+			modified := original.DeepCopy()
+			tc.modify(modified)
+
+			originalHash, err := ObjectHash(original)
+			if err != nil {
+				t.Fatalf("failed hashing object: %s", err)
+			}
+
+			modifiedHash, err := ObjectHash(modified)
+			if err != nil {
+				t.Fatalf("failed hashing modified object: %s", err)
+			}
+
+			if tc.equal {
+				assert.Equal(t, originalHash, modifiedHash, "Hashes should match.")
+				return
+			}
+
+			assert.NotEqual(t, originalHash, modifiedHash, "Hashes should differ.")
+		})
+	}
+}
+
+func TestObjectHashLeavesObjectAlone(t *testing.T) {
+	obj := testConfigMap("hashed", "default")
+	obj.SetResourceVersion("12345")
+
+	_, err := ObjectHash(obj)
+	if err != nil {
+		t.Fatalf("failed hashing object: %s", err)
+	}
+
+	assert.Equal(t, "12345", obj.GetResourceVersion(), "Hashing should not modify the object.")
+}
@@ -0,0 +1,76 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"strings"
+)
+
+// ResourcesAndObjectsFromHelmChart  Renders the chart at chartPath, a directory or packaged .tgz, with the given values, like `helm template --include-crds`, and loads the result.  Nothing is installed, and the cluster isn't consulted while rendering, so lookup() finds nothing.  CRDs from the chart's crds/ directory come first, then the templates, then the hooks, minus `helm test` hooks.  Namespaced objects the chart doesn't put anywhere in particular go into namespace, as they would with `helm install`.
+func (k *K8sClients) ResourcesAndObjectsFromHelmChart(chartPath string, values map[string]interface{}, releaseName string, namespace string) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
+	chart, err := loader.Load(chartPath)
+	if err != nil {
+		err = errors.Wrapf(err, "failed loading chart %s", chartPath)
+		return interfaces, objects, err
+	}
+
+	install := action.NewInstall(&action.Configuration{
+		Log: func(format string, v ...interface{}) {
+			k.log().Info(fmt.Sprintf(format, v...))
+		},
+	})
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.IncludeCRDs = true
+	install.ReleaseName = releaseName
+	install.Namespace = namespace
+
+	rel, err := install.Run(chart, values)
+	if err != nil {
+		err = errors.Wrapf(err, "failed rendering chart %s", chartPath)
+		return interfaces, objects, err
+	}
+
+	manifests := []string{rel.Manifest}
+	for _, hook := range rel.Hooks {
+		if isTestHook(hook) {
+			continue
+		}
+
+		manifests = append(manifests, hook.Manifest)
+	}
+
+	decoded, err := ObjectsFromBytes([]byte(strings.Join(manifests, "\n---\n")))
+	if err != nil {
+		err = errors.Wrapf(err, "failed parsing rendered chart %s", chartPath)
+		return interfaces, objects, err
+	}
+
+	return k.resourcesForObjects(decoded, "", namespace)
+}
+
+// isTestHook  Whether a hook only runs for `helm test`, rather than as part of an install.
+func isTestHook(hook *release.Hook) (test bool) {
+	for _, event := range hook.Events {
+		if event == release.HookTest {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+func TestResourcesAndObjectsFromHelmChart(t *testing.T) {
+	testCases := []struct {
+		name      string
+		chart     string
+		values    map[string]interface{}
+		namespace string
+		expected  []string
+		greeting  string
+		errors    bool
+	}{
+		// helm sorts what it renders into install order, so ConfigMaps come before ClusterRoles
+		{
+			"default values",
+			"test_fixtures/helm/tiny",
+			nil,
+			"charted",
+			[]string{
+				"/CustomResourceDefinition/widgets.utility-client.example.com",
+				"charted/ConfigMap/tiny-config",
+				"/ClusterRole/tiny-reader",
+				"charted/Widget/tiny-widget",
+				"charted/ConfigMap/tiny-hook",
+			},
+			"hello",
+			false,
+		},
+		{
+			"overridden values",
+			"test_fixtures/helm/tiny",
+			map[string]interface{}{"greeting": "howdy"},
+			"charted",
+			[]string{
+				"/CustomResourceDefinition/widgets.utility-client.example.com",
+				"charted/ConfigMap/tiny-config",
+				"/ClusterRole/tiny-reader",
+				"charted/Widget/tiny-widget",
+				"charted/ConfigMap/tiny-hook",
+			},
+			"howdy",
+			false,
+		},
+		{
+			"not a chart",
+			"test_fixtures/manifests",
+			nil,
+			"charted",
+			nil,
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromHelmChart(tc.chart, tc.values, "tiny", tc.namespace)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error rendering %s.", tc.chart)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed rendering chart %s: %s", tc.chart, err)
+			}
+
+			assert.Equal(t, len(objects), len(interfaces), "Interfaces and objects are not aligned.")
+
+			actual := make([]string, 0)
+			for _, o := range objects {
+				actual = append(actual, fmt.Sprintf("%s/%s/%s", o.GetNamespace(), o.GetKind(), o.GetName()))
+
+				if o.GetKind() == "ConfigMap" {
+					greeting, _, _ := unstructured.NestedString(o.Object, "data", "greeting")
+					assert.Equal(t, tc.greeting, greeting, "Rendered %s does not match expectations.", o.GetName())
+				}
+			}
+
+			assert.Equal(t, tc.expected, actual, "Rendered objects do not match expectations.")
+		})
+	}
+}
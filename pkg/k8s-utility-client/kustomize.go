@@ -0,0 +1,37 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// ResourcesAndObjectsFromKustomize  Renders the kustomization in dir, like `kubectl kustomize dir`, and loads the result just like ResourcesAndObjectsFromBytes.  Point it at an overlay to apply that overlay directly.  Bases and resources may live outside dir, as they may with kubectl, but remote bases and plugins are up to kustomize's defaults.
+func (k *K8sClients) ResourcesAndObjectsFromKustomize(dir string) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
+	kustomizer := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+
+	resMap, err := kustomizer.Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		err = errors.Wrapf(err, "failed building kustomization %s", dir)
+		return interfaces, objects, err
+	}
+
+	yamlBytes, err := resMap.AsYaml()
+	if err != nil {
+		err = errors.Wrapf(err, "failed rendering kustomization %s", dir)
+		return interfaces, objects, err
+	}
+
+	return k.ResourcesAndObjectsFromBytes(yamlBytes)
+}
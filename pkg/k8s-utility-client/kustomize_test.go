@@ -0,0 +1,81 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+func TestResourcesAndObjectsFromKustomize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		dir      string
+		expected []string
+		env      string
+		errors   bool
+	}{
+		{
+			"base",
+			"test_fixtures/kustomize/base",
+			[]string{"default/ConfigMap/app-config", "default/Service/app"},
+			"base",
+			false,
+		},
+		{
+			"overlay",
+			"test_fixtures/kustomize/overlay",
+			[]string{"kustomized/ConfigMap/prod-app-config", "kustomized/Service/prod-app"},
+			"prod",
+			false,
+		},
+		{
+			"not a kustomization",
+			"test_fixtures/manifests",
+			nil,
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromKustomize(tc.dir)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error building %s.", tc.dir)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed loading kustomization %s: %s", tc.dir, err)
+			}
+
+			assert.Equal(t, len(objects), len(interfaces), "Interfaces and objects are not aligned.")
+
+			actual := make([]string, 0)
+			for _, o := range objects {
+				actual = append(actual, fmt.Sprintf("%s/%s/%s", o.GetNamespace(), o.GetKind(), o.GetName()))
+
+				if o.GetKind() == "ConfigMap" {
+					env, _, _ := unstructured.NestedString(o.Object, "data", "env")
+					assert.Equal(t, tc.env, env, "Rendered ConfigMap does not match expectations.")
+				}
+			}
+
+			assert.Equal(t, tc.expected, actual, "Rendered objects do not match expectations.")
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// AddLabels  Merges the given labels into the top level metadata.labels of each object, such as `app.kubernetes.io/managed-by` so they can be selected later.  Labels the object already has are not overwritten.  Pod template labels are not touched.
+func AddLabels(objects []*unstructured.Unstructured, labels map[string]string) {
+	for _, obj := range objects {
+		existing := obj.GetLabels()
+		if existing == nil {
+			existing = make(map[string]string)
+		}
+
+		for key, value := range labels {
+			if _, ok := existing[key]; !ok {
+				existing[key] = value
+			}
+		}
+
+		obj.SetLabels(existing)
+	}
+}
+
+// AddAnnotations  Merges the given annotations into the metadata.annotations of each object, such as the git SHA or source file they came from.  Annotations the object already has are not overwritten.
+func AddAnnotations(objects []*unstructured.Unstructured, annotations map[string]string) {
+	for _, obj := range objects {
+		existing := obj.GetAnnotations()
+		if existing == nil {
+			existing = make(map[string]string)
+		}
+
+		for key, value := range annotations {
+			if _, ok := existing[key]; !ok {
+				existing[key] = value
+			}
+		}
+
+		obj.SetAnnotations(existing)
+	}
+}
+
+// SetOwnerReference  Makes owner the controlling owner of each object, so the garbage collector deletes them along with it.  The owner must already exist in the cluster, since the reference needs its UID.  Namespaced objects can only be owned by something in the same namespace, or by a cluster-scoped object.  Objects without a namespace are taken to be bound for DEFAULT_NAMESPACE, where they end up when applied.  An existing reference to the same owner is replaced rather than duplicated.  Objects that already have some other controller are an error, since an object can only have one.  Every object is checked before any is changed, so on error, none are.
+func SetOwnerReference(objects []*unstructured.Unstructured, owner *unstructured.Unstructured) (err error) {
+	if owner.GetUID() == "" {
+		err = errors.New(fmt.Sprintf("owner %s kind %s has no uid.  Has it been created yet?", owner.GetName(), owner.GetKind()))
+		return err
+	}
+
+	isController := true
+	blockOwnerDeletion := true
+
+	ref := metav1.OwnerReference{
+		APIVersion:         owner.GetAPIVersion(),
+		Kind:               owner.GetKind(),
+		Name:               owner.GetName(),
+		UID:                owner.GetUID(),
+		Controller:         &isController,
+		BlockOwnerDeletion: &blockOwnerDeletion,
+	}
+
+	// check everything before touching anything, so a bad object doesn't leave the rest half modified
+	for _, obj := range objects {
+		// an object without a namespace lands in the default one when applied, just as in objectInterface
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = DEFAULT_NAMESPACE
+		}
+
+		if owner.GetNamespace() != "" && namespace != owner.GetNamespace() {
+			err = errors.New(fmt.Sprintf("%s kind %s in namespace %s cannot be owned by %s kind %s in namespace %s", obj.GetName(), obj.GetKind(), namespace, owner.GetName(), owner.GetKind(), owner.GetNamespace()))
+			return err
+		}
+
+		// the API server rejects an object with two controllers, so say so now rather than at apply time
+		for _, existing := range obj.GetOwnerReferences() {
+			if existing.UID != ref.UID && existing.Controller != nil && *existing.Controller {
+				err = errors.New(fmt.Sprintf("%s kind %s is already controlled by %s kind %s", obj.GetName(), obj.GetKind(), existing.Name, existing.Kind))
+				return err
+			}
+		}
+	}
+
+	for _, obj := range objects {
+		refs := make([]metav1.OwnerReference, 0)
+		for _, existing := range obj.GetOwnerReferences() {
+			if existing.UID != ref.UID {
+				refs = append(refs, existing)
+			}
+		}
+
+		obj.SetOwnerReferences(append(refs, ref))
+	}
+
+	return err
+}
@@ -0,0 +1,302 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+func TestAddLabels(t *testing.T) {
+	testCases := []struct {
+		name     string
+		existing map[string]string
+		labels   map[string]string
+		expected map[string]string
+	}{
+		{
+			"no existing labels",
+			nil,
+			map[string]string{"app.kubernetes.io/managed-by": "utility-client"},
+			map[string]string{"app.kubernetes.io/managed-by": "utility-client"},
+		},
+		{
+			"merged with existing labels",
+			map[string]string{"app": "nginx"},
+			map[string]string{"app.kubernetes.io/managed-by": "utility-client"},
+			map[string]string{"app": "nginx", "app.kubernetes.io/managed-by": "utility-client"},
+		},
+		{
+			"existing labels win",
+			map[string]string{"app": "nginx"},
+			map[string]string{"app": "other"},
+			map[string]string{"app": "nginx"},
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := testConfigMap("labelled", "default")
+			obj.SetLabels(tc.existing)
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			client := &K8sClients{DynamicClient: dc}
+			ri := dc.Resource(gvr).Namespace("default")
+
+			err := client.ApplyResourcesWithOptions(context.TODO(), []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{obj}, ApplyOptions{Labels: tc.labels})
+			if err != nil {
+				t.Fatalf("failed applying resources: %s", err)
+			}
+
+			applied, err := ri.Get(context.TODO(), "labelled", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed getting applied resource: %s", err)
+			}
+
+			assert.Equal(t, tc.expected, applied.GetLabels(), "Applied labels do not match expectations.")
+		})
+	}
+}
+
+func TestAddAnnotations(t *testing.T) {
+	testCases := []struct {
+		name        string
+		existing    map[string]string
+		annotations map[string]string
+		expected    map[string]string
+	}{
+		{
+			"no existing annotations",
+			nil,
+			map[string]string{"example.com/git-sha": "abc123"},
+			map[string]string{"example.com/git-sha": "abc123"},
+		},
+		{
+			"merged with existing annotations",
+			map[string]string{"example.com/source": "resources.yaml"},
+			map[string]string{"example.com/git-sha": "abc123"},
+			map[string]string{"example.com/source": "resources.yaml", "example.com/git-sha": "abc123"},
+		},
+		{
+			"existing annotations win",
+			map[string]string{"example.com/git-sha": "def456"},
+			map[string]string{"example.com/git-sha": "abc123"},
+			map[string]string{"example.com/git-sha": "def456"},
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			obj := testConfigMap("annotated", "default")
+			obj.SetAnnotations(tc.existing)
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			client := &K8sClients{DynamicClient: dc}
+			ri := dc.Resource(gvr).Namespace("default")
+
+			err := client.ApplyResourcesWithOptions(context.TODO(), []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{obj}, ApplyOptions{Annotations: tc.annotations})
+			if err != nil {
+				t.Fatalf("failed applying resources: %s", err)
+			}
+
+			applied, err := ri.Get(context.TODO(), "annotated", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed getting applied resource: %s", err)
+			}
+
+			assert.Equal(t, tc.expected, applied.GetAnnotations(), "Applied annotations do not match expectations.")
+		})
+	}
+}
+
+func TestSetOwnerReferenceAllOrNothing(t *testing.T) {
+	isController := true
+
+	testCases := []struct {
+		name  string
+		third *unstructured.Unstructured
+	}{
+		{
+			"bad namespace last",
+			testConfigMap("elsewhere", "elsewhere"),
+		},
+		{
+			"other controller last",
+			func() *unstructured.Unstructured {
+				obj := testConfigMap("controlled", "default")
+				obj.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "other", UID: "other-uid", Controller: &isController}})
+				return obj
+			}(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			owner := testConfigMap("owner", "default")
+			owner.SetUID("owner-uid")
+
+			first := testConfigMap("first", "default")
+			second := testConfigMap("second", "default")
+			before := tc.third.GetOwnerReferences()
+
+			err := SetOwnerReference([]*unstructured.Unstructured{first, second, tc.third}, owner)
+			assert.Error(t, err, "Expected an error setting the owner reference.")
+
+			assert.Empty(t, first.GetOwnerReferences(), "Objects before the bad one should be untouched.")
+			assert.Empty(t, second.GetOwnerReferences(), "Objects before the bad one should be untouched.")
+			assert.Equal(t, before, tc.third.GetOwnerReferences(), "The bad object should be untouched.")
+		})
+	}
+}
+
+func TestSetOwnerReference(t *testing.T) {
+	otherOwner := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "other-uid"}
+	isController := true
+	otherController := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "other", UID: "other-uid", Controller: &isController}
+
+	testCases := []struct {
+		name     string
+		ownerUID types.UID
+		ownerNS  string
+		childNS  string
+		existing []metav1.OwnerReference
+		errors   bool
+		expected []types.UID
+	}{
+		{
+			"new owner",
+			"owner-uid",
+			"default",
+			"default",
+			nil,
+			false,
+			[]types.UID{"owner-uid"},
+		},
+		{
+			"kept alongside other owners",
+			"owner-uid",
+			"default",
+			"default",
+			[]metav1.OwnerReference{otherOwner},
+			false,
+			[]types.UID{"other-uid", "owner-uid"},
+		},
+		{
+			"not duplicated",
+			"owner-uid",
+			"default",
+			"default",
+			[]metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "owner-uid"}},
+			false,
+			[]types.UID{"owner-uid"},
+		},
+		{
+			"replaces itself as controller",
+			"owner-uid",
+			"default",
+			"default",
+			[]metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "owner-uid", Controller: &isController}},
+			false,
+			[]types.UID{"owner-uid"},
+		},
+		{
+			"already controlled by another",
+			"owner-uid",
+			"default",
+			"default",
+			[]metav1.OwnerReference{otherController},
+			true,
+			[]types.UID{},
+		},
+		{
+			"owner without uid",
+			"",
+			"default",
+			"default",
+			nil,
+			true,
+			[]types.UID{},
+		},
+		{
+			"owner in another namespace",
+			"owner-uid",
+			"elsewhere",
+			"default",
+			nil,
+			true,
+			[]types.UID{},
+		},
+		{
+			"child without a namespace",
+			"owner-uid",
+			"default",
+			"",
+			nil,
+			false,
+			[]types.UID{"owner-uid"},
+		},
+		{
+			"child without a namespace, owner in another namespace",
+			"owner-uid",
+			"elsewhere",
+			"",
+			nil,
+			true,
+			[]types.UID{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			owner := testConfigMap("owner", tc.ownerNS)
+			owner.SetUID(tc.ownerUID)
+
+			child := testConfigMap("child", tc.childNS)
+			child.SetOwnerReferences(tc.existing)
+
+			err := SetOwnerReference([]*unstructured.Unstructured{child}, owner)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error setting the owner reference.")
+				assert.Equal(t, tc.existing, child.GetOwnerReferences(), "Owner references should be untouched on error.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed setting owner reference: %s", err)
+			}
+
+			actual := make([]types.UID, 0)
+			for _, ref := range child.GetOwnerReferences() {
+				actual = append(actual, ref.UID)
+			}
+
+			assert.Equal(t, tc.expected, actual, "Owner references do not match expectations.")
+
+			ref := child.GetOwnerReferences()[len(child.GetOwnerReferences())-1]
+			assert.Equal(t, "v1", ref.APIVersion, "Owner reference apiVersion does not match expectations.")
+			assert.Equal(t, "ConfigMap", ref.Kind, "Owner reference kind does not match expectations.")
+			assert.Equal(t, "owner", ref.Name, "Owner reference name does not match expectations.")
+			assert.True(t, *ref.Controller, "Owner reference is not the controller.")
+			assert.True(t, *ref.BlockOwnerDeletion, "Owner reference does not block owner deletion.")
+		})
+	}
+}
@@ -0,0 +1,75 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/metrics"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// latencyBuckets  Histogram buckets for request latencies, in seconds.  The same ones the kube-apiserver's client metrics use, so existing dashboards work.
+var latencyBuckets = []float64{0.005, 0.025, 0.1, 0.25, 0.5, 1.0, 2.0, 4.0, 8.0, 15.0, 30.0, 60.0}
+
+// requestLatency  How long requests to the API server take, by verb and host.
+var requestLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "rest_client_request_duration_seconds",
+	Help:    "Request latency in seconds, by verb and host.",
+	Buckets: latencyBuckets,
+}, []string{"verb", "host"})
+
+// rateLimiterLatency  How long requests were held back by the client side rate limiter, by verb and host.  Long waits here mean QPS and Burst are set too low.
+var rateLimiterLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "rest_client_rate_limiter_duration_seconds",
+	Help:    "Client side rate limiter latency in seconds, by verb and host.",
+	Buckets: latencyBuckets,
+}, []string{"verb", "host"})
+
+// registerMetrics  client-go only accepts one set of metrics per process.
+var registerMetrics sync.Once
+
+// latencyAdapter  Feeds client-go's latency observations into a prometheus histogram.
+type latencyAdapter struct {
+	histogram *prometheus.HistogramVec
+}
+
+// Observe  Records a latency, labeled by verb and host.  The host, rather than the full url, keeps the number of series from growing with every object name.
+func (l latencyAdapter) Observe(ctx context.Context, verb string, u url.URL, latency time.Duration) {
+	l.histogram.WithLabelValues(verb, u.Host).Observe(latency.Seconds())
+}
+
+// EnableMetrics  Exports request latency and rate limiter metrics for every client in the process to the given prometheus registerer, typically prometheus.DefaultRegisterer.  It's safe to call more than once.  client-go only takes the first set of metrics registered in a process, so if something else, such as k8s.io/component-base, got there first, nothing will be observed.
+func EnableMetrics(registerer prometheus.Registerer) (err error) {
+	registerMetrics.Do(func() {
+		metrics.Register(metrics.RegisterOpts{
+			RequestLatency:     latencyAdapter{histogram: requestLatency},
+			RateLimiterLatency: latencyAdapter{histogram: rateLimiterLatency},
+		})
+	})
+
+	for _, collector := range []prometheus.Collector{requestLatency, rateLimiterLatency} {
+		err = registerer.Register(collector)
+		if err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); ok {
+				err = nil
+				continue
+			}
+
+			err = errors.Wrapf(err, "failed registering client metrics")
+			return err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,88 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnableMetrics(t *testing.T) {
+	testCases := []struct {
+		name   string
+		metric string
+	}{
+		{
+			"request latency",
+			"rest_client_request_duration_seconds",
+		},
+		{
+			"rate limiter latency",
+			"rest_client_rate_limiter_duration_seconds",
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind": "Namespace", "apiVersion": "v1", "metadata": {"name": "default"}}`))
+	}))
+	defer server.Close()
+
+	testKubeconfig(t, server.URL)
+
+	registry := prometheus.NewRegistry()
+	err := EnableMetrics(registry)
+	if err != nil {
+		t.Fatalf("failed enabling metrics: %s", err)
+	}
+
+	// enabling twice is harmless
+	err = EnableMetrics(registry)
+	if err != nil {
+		t.Fatalf("failed enabling metrics a second time: %s", err)
+	}
+
+	client, err := NewK8sClientsWithOptions(ClientOptions{ClusterMode: CLUSTER_MODE_OUT_OF_CLUSTER})
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	_, err = client.ClientSet.CoreV1().Namespaces().Get(context.TODO(), "default", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed getting namespace: %s", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("failed gathering metrics: %s", err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var observations uint64
+			for _, family := range families {
+				if family.GetName() != tc.metric {
+					continue
+				}
+
+				for _, m := range family.GetMetric() {
+					observations += m.GetHistogram().GetSampleCount()
+				}
+			}
+
+			assert.True(t, observations > 0, "No observations recorded for %s.", tc.metric)
+		})
+	}
+}
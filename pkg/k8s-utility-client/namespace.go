@@ -0,0 +1,80 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EnsureNamespaceExists  Creates the named namespace if it doesn't already exist, like running `kubectl create ns` first.  An existing namespace is left untouched.
+func (k *K8sClients) EnsureNamespaceExists(ctx context.Context, name string) (err error) {
+	return k.ensureNamespaceExists(ctx, name, nil)
+}
+
+// ensureNamespaceExists  Does the work for EnsureNamespaceExists, optionally as a dry run.
+func (k *K8sClients) ensureNamespaceExists(ctx context.Context, name string, dryRun []string) (err error) {
+	_, err = k.ClientSet.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err == nil {
+		return err
+	}
+
+	if !apierrors.IsNotFound(err) {
+		err = errors.Wrapf(err, "failed getting namespace %s", name)
+		return err
+	}
+
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+	}
+
+	_, err = k.ClientSet.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{DryRun: dryRun})
+	if err != nil {
+		// someone else got there first, which is just as good
+		if apierrors.IsAlreadyExists(err) {
+			err = nil
+			return err
+		}
+
+		err = errors.Wrapf(err, "failed creating namespace %s", name)
+		return err
+	}
+
+	return err
+}
+
+// SetNamespace  Makes name the client's namespace, after checking that it exists on the cluster, so a typo fails here rather than on the first apply.  To skip the check, say when working offline, set Namespace directly.
+func (k *K8sClients) SetNamespace(ctx context.Context, name string) (err error) {
+	if k.ClientSet == nil {
+		err = errors.New(fmt.Sprintf("cannot check namespace %s: no k8s clientset", name))
+		return err
+	}
+
+	_, err = k.ClientSet.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			err = errors.New(fmt.Sprintf("namespace %s does not exist", name))
+			return err
+		}
+
+		err = errors.Wrapf(err, "failed getting namespace %s", name)
+		return err
+	}
+
+	k.Namespace = name
+
+	return err
+}
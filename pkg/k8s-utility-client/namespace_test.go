@@ -0,0 +1,122 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestApplyResourcesEnsureNamespace(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+	}{
+		{
+			"fresh namespace",
+			"test_fixtures/configmap.yaml",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			namespace := fmt.Sprintf("utility-client-%d", time.Now().Unix())
+
+			b, err := os.ReadFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed reading %s: %s", tc.fileName, err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromBytesInNamespace(b, namespace)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Applying resources into namespace %s.\n", namespace)
+			err = client.ApplyResourcesWithOptions(ctx, interfaces, objects, ApplyOptions{EnsureNamespace: true})
+			if err != nil {
+				t.Errorf("failed to apply resources: %s", err)
+			}
+
+			_, err = client.ClientSet.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+			assert.NoError(t, err, "Namespace %s was not created.", namespace)
+
+			// an existing namespace is fine
+			err = client.EnsureNamespaceExists(ctx, namespace)
+			assert.NoError(t, err, "Ensuring an existing namespace errored.")
+
+			fmt.Printf("Cleaning up namespace %s.\n", namespace)
+			err = client.ClientSet.CoreV1().Namespaces().Delete(ctx, namespace, metav1.DeleteOptions{})
+			if err != nil {
+				t.Errorf("failed deleting namespace %s: %s", namespace, err)
+			}
+		})
+	}
+}
+
+func TestSetNamespace(t *testing.T) {
+	testCases := []struct {
+		name      string
+		namespace string
+		errors    bool
+	}{
+		{
+			"existing",
+			"kube-system",
+			false,
+		},
+		{
+			"nonexistent",
+			fmt.Sprintf("utility-client-missing-%d", time.Now().Unix()),
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			original := client.Namespace
+
+			err = client.SetNamespace(context.TODO(), tc.namespace)
+			if tc.errors {
+				assert.Error(t, err, "Setting a nonexistent namespace should error.")
+				assert.Equal(t, original, client.Namespace, "Namespace should be unchanged after an error.")
+				return
+			}
+
+			assert.NoError(t, err, "Failed setting namespace %s.", tc.namespace)
+			assert.Equal(t, tc.namespace, client.Namespace, "Namespace was not set.")
+		})
+	}
+}
+
+func TestSetNamespaceWithoutClientSet(t *testing.T) {
+	client := &K8sClients{Namespace: "foo"}
+
+	err := client.SetNamespace(context.TODO(), "bar")
+	assert.Error(t, err, "Setting a namespace without a clientset should error.")
+	assert.Equal(t, "foo", client.Namespace, "Namespace should be unchanged after an error.")
+}
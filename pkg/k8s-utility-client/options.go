@@ -0,0 +1,132 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// VERSION  The version of this library, reported in the default User-Agent.
+const VERSION = "0.1.0"
+
+// DEFAULT_USER_AGENT  The User-Agent sent to the API server, whichever constructor made the client, unless ClientOptions specifies another.
+const DEFAULT_USER_AGENT = "k8s-utility-client/" + VERSION
+
+// ClusterMode  Whether the client should configure itself from inside a k8s pod, or from kubeconfig files.
+type ClusterMode string
+
+// CLUSTER_MODE_AUTO  Decide based on whether IN_POD_NAMESPACE_FILE exists.  The default.
+const CLUSTER_MODE_AUTO ClusterMode = ""
+
+// CLUSTER_MODE_IN_CLUSTER  Use the pod's service account, whether or not IN_POD_NAMESPACE_FILE exists.
+const CLUSTER_MODE_IN_CLUSTER ClusterMode = "in-cluster"
+
+// CLUSTER_MODE_OUT_OF_CLUSTER  Use kubeconfig files, even when running in a pod, e.g. a CI job targeting some other cluster.
+const CLUSTER_MODE_OUT_OF_CLUSTER ClusterMode = "out-of-cluster"
+
+// ClientOptions  Tunes the rest.Config used to build the clientsets.  Zero values leave the client-go defaults in place.
+type ClientOptions struct {
+	// QPS  Sustained queries per second allowed against the API server.  client-go defaults to 5, which throttles bulk applies of many resources.  Raising it, along with Burst, speeds those up considerably.
+	QPS float32
+	// Burst  Maximum burst of queries allowed above QPS.  client-go defaults to 10.
+	Burst int
+	// UserAgent  Sent with every request, and recorded in the API server's audit logs.  Set it to the name of your tool to make it obvious who sent a request.  Defaults to DEFAULT_USER_AGENT.
+	UserAgent string
+	// Impersonate  Act as another user, group, or service account, e.g. to check what a service account is allowed to do.  The credentials in the kubeconfig must be permitted to impersonate.
+	Impersonate rest.ImpersonationConfig
+	// InsecureSkipTLSVerify  Don't verify the API server's certificate.  THIS IS INSECURE.  Anyone between you and the API server can read and alter your requests, credentials included.  It's only meant for throwaway dev clusters with self-signed certificates.  Any CA in the kubeconfig is dropped, since client-go refuses a config with both.
+	InsecureSkipTLSVerify bool
+	// ProxyURL  Reach the API server through this HTTP proxy, e.g. "http://proxy.example.com:3128".  By default, client-go honors the HTTPS_PROXY and NO_PROXY environment variables.
+	ProxyURL string
+	// ClusterMode  Forces in-cluster or out-of-cluster configuration, rather than guessing from the existence of IN_POD_NAMESPACE_FILE.  Defaults to CLUSTER_MODE_AUTO.
+	ClusterMode ClusterMode
+	// RequestTimeout  The longest any single request to the API server may take, so a call fails fast instead of hanging on a stuck API server.  It bounds each request separately; a deadline on the context passed in bounds the whole operation, and whichever comes first wins.  client-go sets no limit by default.
+	RequestTimeout time.Duration
+	// DiscoveryCacheDir  Cache what the API server supports on disk here, like kubectl does in ~/.kube/cache, instead of just in memory.  Discovery is slow, and a cluster's API rarely changes between runs of a CLI, so repeated runs start much faster.  Off by default.
+	DiscoveryCacheDir string
+	// DiscoveryCacheTTL  How long discovery data in DiscoveryCacheDir is trusted before going back to the server.  Defaults to DEFAULT_DISCOVERY_CACHE_TTL.
+	DiscoveryCacheTTL time.Duration
+	// Metrics  Export request latency and rate limiter metrics to prometheus.DefaultRegisterer.  See EnableMetrics() to use some other registry.
+	Metrics bool
+	// WarningHandler  Receives the warnings the API server sends, such as API deprecations, instead of Warnings().  Use rest.NoWarnings{} to drop them.
+	WarningHandler rest.WarningHandler
+}
+
+// inCluster  Works out whether to configure the client from inside a pod.
+func (o ClientOptions) inCluster() (inCluster bool, err error) {
+	switch o.ClusterMode {
+	case CLUSTER_MODE_IN_CLUSTER:
+		return true, err
+	case CLUSTER_MODE_OUT_OF_CLUSTER:
+		return false, err
+	case CLUSTER_MODE_AUTO:
+		// If we're in a cluster, IN_POD_NAMESPACE_FILE will exist
+		_, statErr := os.Stat(IN_POD_NAMESPACE_FILE)
+		return !os.IsNotExist(statErr), err
+	default:
+		err = errors.New(fmt.Sprintf("unknown cluster mode %q", o.ClusterMode))
+		return false, err
+	}
+}
+
+// apply  Sets the options on the given rest.Config.
+func (o ClientOptions) apply(config *rest.Config) (err error) {
+	if config == nil {
+		return err
+	}
+
+	if o.QPS > 0 {
+		config.QPS = o.QPS
+	}
+
+	if o.Burst > 0 {
+		config.Burst = o.Burst
+	}
+
+	if o.RequestTimeout > 0 {
+		config.Timeout = o.RequestTimeout
+	}
+
+	if o.UserAgent != "" {
+		config.UserAgent = o.UserAgent
+	}
+
+	if o.Impersonate.UserName != "" || o.Impersonate.UID != "" || len(o.Impersonate.Groups) > 0 || len(o.Impersonate.Extra) > 0 {
+		config.Impersonate = o.Impersonate
+	}
+
+	if o.InsecureSkipTLSVerify {
+		config.TLSClientConfig.Insecure = true
+		config.TLSClientConfig.CAData = nil
+		config.TLSClientConfig.CAFile = ""
+	}
+
+	if o.WarningHandler != nil {
+		config.WarningHandler = o.WarningHandler
+	}
+
+	if o.ProxyURL != "" {
+		proxyURL, err := url.Parse(o.ProxyURL)
+		if err != nil {
+			err = errors.Wrapf(err, "failed parsing proxy url %s", o.ProxyURL)
+			return err
+		}
+
+		config.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return err
+}
@@ -0,0 +1,335 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewK8sClientsWithOptions(t *testing.T) {
+	if _, err := os.Stat(IN_POD_NAMESPACE_FILE); !os.IsNotExist(err) {
+		t.Skip("running in a k8s pod.  The KUBECONFIG environment variable is not consulted in cluster.")
+	}
+
+	kubeconfig := filepath.Join(tmpDir, "options-kubeconfig")
+
+	b, err := os.ReadFile("test_fixtures/kubeconfig.yaml")
+	if err != nil {
+		t.Fatalf("failed reading kubeconfig fixture: %s", err)
+	}
+
+	err = os.WriteFile(kubeconfig, b, 0600)
+	if err != nil {
+		t.Fatalf("failed writing temp kubeconfig: %s", err)
+	}
+
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	testCases := []struct {
+		name        string
+		opts        ClientOptions
+		qps         float32
+		burst       int
+		userAgent   string
+		impersonate rest.ImpersonationConfig
+		timeout     time.Duration
+	}{
+		{
+			"defaults",
+			ClientOptions{},
+			0,
+			0,
+			DEFAULT_USER_AGENT,
+			rest.ImpersonationConfig{},
+			0,
+		},
+		{
+			"qps and burst",
+			ClientOptions{QPS: 50, Burst: 100},
+			50,
+			100,
+			DEFAULT_USER_AGENT,
+			rest.ImpersonationConfig{},
+			0,
+		},
+		{
+			"user agent",
+			ClientOptions{UserAgent: "my-controller/1.2.3"},
+			0,
+			0,
+			"my-controller/1.2.3",
+			rest.ImpersonationConfig{},
+			0,
+		},
+		{
+			"impersonation",
+			ClientOptions{Impersonate: rest.ImpersonationConfig{
+				UserName: "system:serviceaccount:default:builder",
+				Groups:   []string{"system:serviceaccounts"},
+				Extra:    map[string][]string{"scopes": {"view"}},
+			}},
+			0,
+			0,
+			DEFAULT_USER_AGENT,
+			rest.ImpersonationConfig{
+				UserName: "system:serviceaccount:default:builder",
+				Groups:   []string{"system:serviceaccounts"},
+				Extra:    map[string][]string{"scopes": {"view"}},
+			},
+			0,
+		},
+		{
+			"request timeout",
+			ClientOptions{RequestTimeout: 30 * time.Second},
+			0,
+			0,
+			DEFAULT_USER_AGENT,
+			rest.ImpersonationConfig{},
+			30 * time.Second,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClientsWithOptions(tc.opts)
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			assert.Equal(t, tc.qps, client.K8SConfig.QPS, "QPS not applied to the rest.Config.")
+			assert.Equal(t, tc.burst, client.K8SConfig.Burst, "Burst not applied to the rest.Config.")
+			assert.Equal(t, tc.userAgent, client.K8SConfig.UserAgent, "UserAgent not applied to the rest.Config.")
+			assert.Equal(t, tc.impersonate, client.K8SConfig.Impersonate, "ImpersonationConfig not applied to the rest.Config.")
+			assert.Equal(t, tc.timeout, client.K8SConfig.Timeout, "RequestTimeout not applied to the rest.Config.")
+			assert.NotNil(t, client.ClientSet, "ClientSet was not created.")
+		})
+	}
+}
+
+func TestDefaultUserAgent(t *testing.T) {
+	kubeconfig, err := os.ReadFile("test_fixtures/kubeconfig.yaml")
+	if err != nil {
+		t.Fatalf("failed reading kubeconfig fixture: %s", err)
+	}
+
+	testCases := []struct {
+		name        string
+		constructor func() (*K8sClients, error)
+	}{
+		{
+			"config path",
+			func() (*K8sClients, error) {
+				return NewK8sClientsWithConfigPath("test_fixtures/kubeconfig.yaml")
+			},
+		},
+		{
+			"kubeconfig contents",
+			func() (*K8sClients, error) {
+				return NewK8sClientsFromKubeconfig(kubeconfig)
+			},
+		},
+		{
+			"token",
+			func() (*K8sClients, error) {
+				return NewK8sClientsWithToken("https://127.0.0.1:6443", nil, "secret")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := tc.constructor()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			assert.Equal(t, DEFAULT_USER_AGENT, client.K8SConfig.UserAgent, "Client does not identify itself with the default User-Agent.")
+		})
+	}
+}
+
+func TestNewK8sClientsClusterMode(t *testing.T) {
+	kubeconfig := filepath.Join(tmpDir, "cluster-mode-kubeconfig")
+
+	b, err := os.ReadFile("test_fixtures/kubeconfig.yaml")
+	if err != nil {
+		t.Fatalf("failed reading kubeconfig fixture: %s", err)
+	}
+
+	err = os.WriteFile(kubeconfig, b, 0600)
+	if err != nil {
+		t.Fatalf("failed writing temp kubeconfig: %s", err)
+	}
+
+	t.Setenv("KUBECONFIG", kubeconfig)
+
+	// without these, rest.InClusterConfig() reliably fails with ErrNotInCluster, even when the tests happen to run in a pod
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+
+	testCases := []struct {
+		name      string
+		mode      ClusterMode
+		inCluster bool
+		err       error
+		host      string
+	}{
+		{
+			"forced out of cluster",
+			CLUSTER_MODE_OUT_OF_CLUSTER,
+			false,
+			nil,
+			"https://alpha.example.com:6443",
+		},
+		{
+			"forced in cluster",
+			CLUSTER_MODE_IN_CLUSTER,
+			true,
+			rest.ErrNotInCluster,
+			"",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClientsWithOptions(ClientOptions{ClusterMode: tc.mode})
+			assert.Equal(t, tc.inCluster, client.InCluster, "InCluster does not match the forced mode.")
+
+			if tc.err != nil {
+				assert.Equal(t, tc.err, errors.Cause(err), "Expected the in-cluster config to be attempted.  Got: %s", err)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			assert.Equal(t, tc.host, client.K8SConfig.Host, "Host does not match the kubeconfig.")
+		})
+	}
+}
+
+func TestNewK8sClientsUnknownClusterMode(t *testing.T) {
+	_, err := NewK8sClientsWithOptions(ClientOptions{ClusterMode: "sideways"})
+	assert.Error(t, err, "Expected an error for an unknown cluster mode.")
+}
+
+func TestClientOptionsInsecureSkipTLSVerify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		insecure bool
+	}{
+		{
+			"verified",
+			false,
+		},
+		{
+			"insecure",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &rest.Config{
+				Host: "https://alpha.example.com:6443",
+				TLSClientConfig: rest.TLSClientConfig{
+					CAFile: "/etc/kubernetes/pki/ca.crt",
+					CAData: []byte("not-a-real-ca"),
+				},
+			}
+
+			err := ClientOptions{InsecureSkipTLSVerify: tc.insecure}.apply(config)
+			if err != nil {
+				t.Fatalf("failed applying options: %s", err)
+			}
+
+			assert.Equal(t, tc.insecure, config.Insecure, "Insecure not applied to the rest.Config.")
+
+			if tc.insecure {
+				assert.Empty(t, config.CAFile, "CAFile should be cleared when skipping verification.")
+				assert.Empty(t, config.CAData, "CAData should be cleared when skipping verification.")
+
+				// client-go refuses a config that's both insecure and has a CA
+				_, err = kubernetes.NewForConfig(config)
+				assert.NoError(t, err, "Insecure rest.Config was rejected.")
+			}
+		})
+	}
+}
+
+func TestClientOptionsProxyURL(t *testing.T) {
+	testCases := []struct {
+		name     string
+		proxyURL string
+		expected string
+		errors   bool
+	}{
+		{
+			"no proxy",
+			"",
+			"",
+			false,
+		},
+		{
+			"proxy",
+			"http://proxy.example.com:3128",
+			"http://proxy.example.com:3128",
+			false,
+		},
+		{
+			"unparseable",
+			"http://proxy.example.com:port",
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &rest.Config{Host: "https://alpha.example.com:6443"}
+
+			err := ClientOptions{ProxyURL: tc.proxyURL}.apply(config)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error parsing the proxy url.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed applying options: %s", err)
+			}
+
+			if tc.expected == "" {
+				assert.Nil(t, config.Proxy, "Proxy should be left unset.")
+				return
+			}
+
+			req, err := http.NewRequest(http.MethodGet, config.Host, nil)
+			if err != nil {
+				t.Fatalf("failed creating request: %s", err)
+			}
+
+			proxyURL, err := config.Proxy(req)
+			if err != nil {
+				t.Fatalf("failed getting proxy url: %s", err)
+			}
+
+			assert.Equal(t, tc.expected, proxyURL.String(), "Proxy url does not match expectations.")
+		})
+	}
+}
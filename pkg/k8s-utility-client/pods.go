@@ -0,0 +1,161 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// PodLogs  Streams the logs of a pod.  Set Container, Follow, TailLines, etc on opts just like `kubectl logs`.  The caller must Close() the returned stream.
+func (k *K8sClients) PodLogs(ctx context.Context, namespace string, podName string, opts corev1.PodLogOptions) (logs io.ReadCloser, err error) {
+	logs, err = k.ClientSet.CoreV1().Pods(namespace).GetLogs(podName, &opts).Stream(ctx)
+	if err != nil {
+		err = errors.Wrapf(err, "failed streaming logs for pod %s in namespace %s", podName, namespace)
+		return logs, err
+	}
+
+	return logs, err
+}
+
+// ExecInPod  Runs a command in a container of a running pod, like `kubectl exec`, wiring up whichever of stdin, stdout, and stderr are non-nil.  No TTY is allocated.  If ctx is cancelled, ExecInPod returns immediately with ctx.Err(), though the command may carry on running in the pod until it finishes.
+func (k *K8sClients) ExecInPod(ctx context.Context, namespace string, podName string, containerName string, command []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) (err error) {
+	req := k.ClientSet.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: containerName,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    stdout != nil,
+			Stderr:    stderr != nil,
+			TTY:       false,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(k.K8SConfig, http.MethodPost, req.URL())
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating executor for pod %s in namespace %s", podName, namespace)
+		return err
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- executor.Stream(remotecommand.StreamOptions{
+			Stdin:  stdin,
+			Stdout: stdout,
+			Stderr: stderr,
+			Tty:    false,
+		})
+	}()
+
+	select {
+	case err = <-errCh:
+		if err != nil {
+			err = errors.Wrapf(err, "failed executing %q in pod %s in namespace %s", strings.Join(command, " "), podName, namespace)
+			return err
+		}
+	case <-ctx.Done():
+		err = ctx.Err()
+		return err
+	}
+
+	return err
+}
+
+// PortForward  Forwards local ports to a pod, like `kubectl port-forward`.  Ports are specified the same way too: "8080:80" forwards local port 8080 to port 80 in the pod, and ":80" forwards a random local port to port 80.  Returns once the forward is ready, with the local ports actually bound, in the same order as ports.  Forwarding carries on in the background until stopCh is closed or ctx is cancelled.  stopCh may be nil, but then ctx must be one that gets cancelled, or the forward runs for the life of the process.  On error, nothing is left running.
+func (k *K8sClients) PortForward(ctx context.Context, namespace string, podName string, ports []string, stopCh <-chan struct{}) (localPorts []int, err error) {
+	localPorts = make([]int, 0)
+
+	roundTripper, upgrader, err := spdy.RoundTripperFor(k.K8SConfig)
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating round tripper for port forward")
+		return localPorts, err
+	}
+
+	url := k.ClientSet.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(podName).
+		SubResource("portforward").
+		URL()
+
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: roundTripper}, http.MethodPost, url)
+
+	// The forwarder only knows about one stop channel, so merge the caller's stopCh and ctx into it.  done stops it too, so that bailing out early, or the forward ending by itself, doesn't leave this goroutine waiting forever.
+	forwardStopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	done := make(chan struct{})
+
+	var doneOnce sync.Once
+	finish := func() {
+		doneOnce.Do(func() { close(done) })
+	}
+
+	go func() {
+		select {
+		case <-stopCh:
+		case <-ctx.Done():
+		case <-done:
+		}
+
+		close(forwardStopCh)
+	}()
+
+	forwarder, err := portforward.New(dialer, ports, forwardStopCh, readyCh, io.Discard, io.Discard)
+	if err != nil {
+		finish()
+		err = errors.Wrapf(err, "failed creating port forward to pod %s in namespace %s", podName, namespace)
+		return localPorts, err
+	}
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- forwarder.ForwardPorts()
+		finish()
+	}()
+
+	select {
+	case <-readyCh:
+	case err = <-errCh:
+		finish()
+		if err == nil {
+			err = errors.New("port forward stopped before it was ready")
+		}
+
+		err = errors.Wrapf(err, "failed forwarding ports to pod %s in namespace %s", podName, namespace)
+		return localPorts, err
+	}
+
+	forwarded, err := forwarder.GetPorts()
+	if err != nil {
+		finish()
+		err = errors.Wrapf(err, "failed getting forwarded ports")
+		return localPorts, err
+	}
+
+	for _, port := range forwarded {
+		localPorts = append(localPorts, int(port.Local))
+	}
+
+	return localPorts, err
+}
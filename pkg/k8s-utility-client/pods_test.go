@@ -0,0 +1,272 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPodLogs(t *testing.T) {
+	tailLines := int64(10)
+
+	testCases := []struct {
+		name     string
+		fileName string
+		opts     corev1.PodLogOptions
+	}{
+		{
+			"tail",
+			"test_fixtures/pod.yaml",
+			corev1.PodLogOptions{Container: "nginx", TailLines: &tailLines},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Creating resources in k8s.\n")
+			err = client.ApplyResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed to apply resources: %s", err)
+			}
+
+			defer func() {
+				fmt.Printf("Cleaning up resources in k8s.\n")
+				err = client.DeleteResources(ctx, interfaces, objects)
+				if err != nil {
+					t.Errorf("failed deleting resources: %s", err)
+				}
+			}()
+
+			err = client.WaitForReady(ctx, interfaces, objects, 2*time.Minute)
+			if err != nil {
+				t.Fatalf("pod never became ready: %s", err)
+			}
+
+			pod := objects[0]
+
+			logs, err := client.PodLogs(ctx, pod.GetNamespace(), pod.GetName(), tc.opts)
+			if err != nil {
+				t.Fatalf("failed streaming logs: %s", err)
+			}
+
+			defer logs.Close()
+
+			b, err := io.ReadAll(logs)
+			if err != nil {
+				t.Fatalf("failed reading logs: %s", err)
+			}
+
+			assert.NotEmpty(t, b, "No logs were read from the pod.")
+		})
+	}
+}
+
+func TestExecInPod(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+		command  []string
+		expected string
+	}{
+		{
+			"echo",
+			"test_fixtures/pod.yaml",
+			[]string{"echo", "hello from the pod"},
+			"hello from the pod\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Creating resources in k8s.\n")
+			err = client.ApplyResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed to apply resources: %s", err)
+			}
+
+			defer func() {
+				fmt.Printf("Cleaning up resources in k8s.\n")
+				err = client.DeleteResources(ctx, interfaces, objects)
+				if err != nil {
+					t.Errorf("failed deleting resources: %s", err)
+				}
+			}()
+
+			err = client.WaitForReady(ctx, interfaces, objects, 2*time.Minute)
+			if err != nil {
+				t.Fatalf("pod never became ready: %s", err)
+			}
+
+			pod := objects[0]
+
+			var stdout, stderr bytes.Buffer
+
+			err = client.ExecInPod(ctx, pod.GetNamespace(), pod.GetName(), "nginx", tc.command, nil, &stdout, &stderr)
+			if err != nil {
+				t.Fatalf("failed executing command: %s.  Stderr: %s", err, stderr.String())
+			}
+
+			assert.Equal(t, tc.expected, stdout.String(), "Command output does not match expectations.")
+		})
+	}
+}
+
+func TestPortForward(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+		ports    []string
+	}{
+		{
+			"random local port",
+			"test_fixtures/pod.yaml",
+			[]string{":80"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Creating resources in k8s.\n")
+			err = client.ApplyResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed to apply resources: %s", err)
+			}
+
+			defer func() {
+				fmt.Printf("Cleaning up resources in k8s.\n")
+				err = client.DeleteResources(ctx, interfaces, objects)
+				if err != nil {
+					t.Errorf("failed deleting resources: %s", err)
+				}
+			}()
+
+			err = client.WaitForReady(ctx, interfaces, objects, 2*time.Minute)
+			if err != nil {
+				t.Fatalf("pod never became ready: %s", err)
+			}
+
+			pod := objects[0]
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+
+			localPorts, err := client.PortForward(ctx, pod.GetNamespace(), pod.GetName(), tc.ports, stopCh)
+			if err != nil {
+				t.Fatalf("failed forwarding ports: %s", err)
+			}
+
+			if !assert.Len(t, localPorts, len(tc.ports), "Unexpected number of forwarded ports.") {
+				return
+			}
+
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%d/", localPorts[0]))
+			if err != nil {
+				t.Fatalf("failed requesting forwarded port: %s", err)
+			}
+
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode, "Unexpected response through the forwarded port.")
+		})
+	}
+}
+
+func TestPortForwardFailureCleansUp(t *testing.T) {
+	testCases := []struct {
+		name  string
+		ports []string
+	}{
+		{
+			"bad port",
+			[]string{"not-a-port"},
+		},
+		{
+			"upgrade refused",
+			[]string{":80"},
+		},
+	}
+
+	// no kubelet behind this one, so the forward can never get going
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	testKubeconfig(t, server.URL)
+
+	client, err := NewK8sClientsWithOptions(ClientOptions{ClusterMode: CLUSTER_MODE_OUT_OF_CLUSTER})
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			// neither a stop channel nor a cancellable context, so nothing but PortForward itself can clean up
+			_, err := client.PortForward(context.Background(), "default", "nowhere", tc.ports, nil)
+			assert.Error(t, err, "Expected an error forwarding ports.")
+
+			server.CloseClientConnections()
+
+			deadline := time.Now().Add(5 * time.Second)
+			for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			assert.LessOrEqual(t, runtime.NumGoroutine(), before, "PortForward left goroutines running.")
+		})
+	}
+}
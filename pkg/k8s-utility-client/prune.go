@@ -0,0 +1,225 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
+)
+
+// PruneOptions  Controls what ApplyAndPruneWithOptions looks at when pruning, and what it does when a delete fails.  The zero value behaves exactly like ApplyAndPrune.
+type PruneOptions struct {
+	// Kinds  The kinds to look for leftovers of, on top of the kinds among the objects.  A kind that's been dropped from the manifest entirely can only be pruned if it's listed here.  Defaults to DefaultPruneKinds(), the same allowlist `kubectl apply --prune` uses.  Kinds the cluster doesn't serve are skipped.
+	Kinds []schema.GroupVersionKind
+	// Namespaces  Where to look for leftovers of namespaced kinds.  Defaults to the namespaces among the objects, or the client's namespace if there are none.  A namespace that's been dropped from the manifest entirely can only be emptied if it's listed here.  metav1.NamespaceAll looks in every namespace.
+	Namespaces []string
+	// ContinueOnError  Applies to both the apply and the pruning.  Carry on with the rest of the objects when one fails, rather than stopping at the first failure.  Every failure is returned together in a utilerrors.Aggregate.  Nothing is pruned if the apply fails, since a half applied manifest is no basis for deciding what's left over.
+	ContinueOnError bool
+}
+
+// DefaultPruneKinds  The kinds pruned by default, even when none of them are left among the objects.  It's the allowlist `kubectl apply --prune` uses: the everyday workload, config, and networking kinds.
+func DefaultPruneKinds() (kinds []schema.GroupVersionKind) {
+	kinds = []schema.GroupVersionKind{
+		{Version: "v1", Kind: "ConfigMap"},
+		{Version: "v1", Kind: "Endpoints"},
+		{Version: "v1", Kind: "Namespace"},
+		{Version: "v1", Kind: "PersistentVolumeClaim"},
+		{Version: "v1", Kind: "PersistentVolume"},
+		{Version: "v1", Kind: "Pod"},
+		{Version: "v1", Kind: "ReplicationController"},
+		{Version: "v1", Kind: "Secret"},
+		{Version: "v1", Kind: "Service"},
+		{Group: "batch", Version: "v1", Kind: "Job"},
+		{Group: "batch", Version: "v1", Kind: "CronJob"},
+		{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+		{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+		{Group: "apps", Version: "v1", Kind: "Deployment"},
+		{Group: "apps", Version: "v1", Kind: "ReplicaSet"},
+		{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+	}
+
+	return kinds
+}
+
+// ApplyAndPrune  Applies objects like ApplyResources, then deletes anything matching pruneLabelSelector that's no longer among them, like `kubectl apply --prune`.  The selector's labels are stamped onto every object, so whatever is applied now can be found and pruned later.  The selector must therefore be a plain set of key=value pairs, such as "app.kubernetes.io/managed-by=my-tool", and can't be empty, lest it match everything in the cluster.  The kinds among the objects, and those in DefaultPruneKinds(), are considered for pruning, in the namespaces among the objects.  Use ApplyAndPruneWithOptions to choose for yourself.
+func (k *K8sClients) ApplyAndPrune(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, pruneLabelSelector string) (err error) {
+	return k.ApplyAndPruneWithOptions(ctx, interfaces, objects, pruneLabelSelector, PruneOptions{})
+}
+
+// ApplyAndPruneWithOptions  Applies and prunes like ApplyAndPrune, but lets the caller choose which kinds and namespaces are pruned, and whether to carry on past failures, via PruneOptions.
+func (k *K8sClients) ApplyAndPruneWithOptions(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, pruneLabelSelector string, opts PruneOptions) (err error) {
+	pruneLabels, err := labels.ConvertSelectorToLabelsMap(pruneLabelSelector)
+	if err != nil {
+		err = errors.Wrapf(err, "failed parsing prune label selector %q", pruneLabelSelector)
+		return err
+	}
+
+	if len(pruneLabels) == 0 {
+		err = errors.New("refusing to prune with an empty label selector")
+		return err
+	}
+
+	// the selector's labels have to win over whatever the objects say, or they'd escape pruning next time
+	for _, obj := range objects {
+		objLabels := obj.GetLabels()
+		if objLabels == nil {
+			objLabels = make(map[string]string)
+		}
+
+		for key, value := range pruneLabels {
+			objLabels[key] = value
+		}
+
+		obj.SetLabels(objLabels)
+	}
+
+	err = k.ApplyResourcesWithOptions(ctx, interfaces, objects, ApplyOptions{ContinueOnError: opts.ContinueOnError})
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool)
+	kinds := make([]schema.GroupVersionKind, 0)
+	seenKinds := make(map[schema.GroupKind]bool)
+	namespaces := make([]string, 0)
+	seenNamespaces := make(map[string]bool)
+
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		keep[pruneKey(gvk, obj.GetNamespace(), obj.GetName())] = true
+
+		if !seenKinds[gvk.GroupKind()] {
+			seenKinds[gvk.GroupKind()] = true
+			kinds = append(kinds, gvk)
+		}
+
+		if obj.GetNamespace() != "" && !seenNamespaces[obj.GetNamespace()] {
+			seenNamespaces[obj.GetNamespace()] = true
+			namespaces = append(namespaces, obj.GetNamespace())
+		}
+	}
+
+	// kinds that are gone from the manifest altogether can only be found if we're told to look for them
+	extraKinds := opts.Kinds
+	if len(extraKinds) == 0 {
+		extraKinds = DefaultPruneKinds()
+	}
+
+	for _, gvk := range extraKinds {
+		if !seenKinds[gvk.GroupKind()] {
+			seenKinds[gvk.GroupKind()] = true
+			kinds = append(kinds, gvk)
+		}
+	}
+
+	if len(opts.Namespaces) > 0 {
+		namespaces = opts.Namespaces
+	}
+
+	if len(namespaces) == 0 {
+		namespaces = []string{k.CurrentNamespace()}
+	}
+
+	// only used with ContinueOnError
+	errs := make([]error, 0)
+
+	for _, gvk := range kinds {
+		mapping, err := k.restMapping(gvk)
+		if err != nil {
+			// not every cluster serves everything on the allowlist
+			if meta.IsNoMatchError(errors.Cause(err)) {
+				continue
+			}
+
+			if opts.ContinueOnError {
+				errs = append(errs, err)
+				continue
+			}
+
+			return err
+		}
+
+		// cluster-scoped kinds only need looking at once
+		scopes := namespaces
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			scopes = []string{metav1.NamespaceAll}
+		}
+
+		for _, namespace := range scopes {
+			err = k.pruneKind(ctx, mapping, namespace, pruneLabels, keep, opts.ContinueOnError)
+			if err != nil {
+				if opts.ContinueOnError {
+					errs = append(errs, err)
+					continue
+				}
+
+				return err
+			}
+		}
+	}
+
+	err = utilerrors.NewAggregate(errs)
+
+	return err
+}
+
+// pruneKind  Deletes the objects of a mapped kind in namespace that carry pruneLabels, but aren't in keep.  With continueOnError, every delete is attempted, and the error aggregates every failure.
+func (k *K8sClients) pruneKind(ctx context.Context, mapping *meta.RESTMapping, namespace string, pruneLabels labels.Set, keep map[string]bool, continueOnError bool) (err error) {
+	gvk := mapping.GroupVersionKind
+
+	list, err := k.DynamicClient.Resource(mapping.Resource).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: pruneLabels.String()})
+	if err != nil {
+		err = errors.Wrapf(err, "failed listing kind %s for pruning", gvk.Kind)
+		return err
+	}
+
+	// only used with continueOnError
+	errs := make([]error, 0)
+
+	for _, live := range list.Items {
+		if keep[pruneKey(gvk, live.GetNamespace(), live.GetName())] {
+			continue
+		}
+
+		// with NamespaceAll, each object has to be deleted from its own namespace
+		ri := k.mappingInterface(mapping, live.GetNamespace())
+
+		k.log().Info("Pruning", "kind", gvk.Kind, "name", live.GetName(), "namespace", live.GetNamespace())
+		err = retryTransient(ctx, func() error {
+			return ri.Delete(ctx, live.GetName(), metav1.DeleteOptions{PropagationPolicy: DeleteOptions{}.propagationPolicy()})
+		})
+		if err != nil && !IsNotFound(err) {
+			err = errors.Wrapf(err, "failed pruning %s kind %s", live.GetName(), gvk.Kind)
+			if continueOnError {
+				errs = append(errs, err)
+				continue
+			}
+
+			return err
+		}
+	}
+
+	err = utilerrors.NewAggregate(errs)
+
+	return err
+}
+
+// pruneKey  Identifies an object for the purposes of pruning.
+func pruneKey(gvk schema.GroupVersionKind, namespace string, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", gvk.Group, gvk.Kind, namespace, name)
+}
@@ -0,0 +1,303 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sort"
+	"testing"
+)
+
+// testPruneListKinds  The list kinds the fake dynamic client needs for every kind in testRESTMapper(), since pruning lists whatever it can map.
+func testPruneListKinds() (listKinds map[schema.GroupVersionResource]string) {
+	listKinds = map[schema.GroupVersionResource]string{
+		{Version: "v1", Resource: "configmaps"}:                                               "ConfigMapList",
+		{Version: "v1", Resource: "services"}:                                                 "ServiceList",
+		{Version: "v1", Resource: "namespaces"}:                                               "NamespaceList",
+		{Group: "apps", Version: "v1", Resource: "deployments"}:                               "DeploymentList",
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"}:         "ClusterRoleList",
+		{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}: "CustomResourceDefinitionList",
+	}
+
+	return listKinds
+}
+
+// testPruneObject  A bare object of the given kind, for pruning.
+func testPruneObject(apiVersion string, kind string, namespace string, name string) (obj *unstructured.Unstructured) {
+	obj = &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata": map[string]interface{}{
+				"name": name,
+			},
+		},
+	}
+
+	if namespace != "" {
+		obj.SetNamespace(namespace)
+	}
+
+	return obj
+}
+
+func TestApplyAndPrune(t *testing.T) {
+	testCases := []struct {
+		name     string
+		first    []string
+		second   []string
+		selector string
+		expected []string
+		errors   bool
+	}{
+		{
+			"prune removed object",
+			[]string{"a", "b"},
+			[]string{"a"},
+			"app.kubernetes.io/managed-by=utility-client-test",
+			[]string{"a", "unmanaged"},
+			false,
+		},
+		{
+			"nothing to prune",
+			[]string{"a", "b"},
+			[]string{"a", "b"},
+			"app.kubernetes.io/managed-by=utility-client-test",
+			[]string{"a", "b", "unmanaged"},
+			false,
+		},
+		{
+			"empty selector",
+			[]string{"a", "b"},
+			[]string{"a"},
+			"",
+			[]string{},
+			true,
+		},
+		{
+			"set based selector",
+			[]string{"a", "b"},
+			[]string{"a"},
+			"app.kubernetes.io/managed-by in (utility-client-test)",
+			[]string{},
+			true,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), testPruneListKinds(), testConfigMap("unmanaged", "default"))
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+			ctx := context.TODO()
+
+			apply := func(names []string) (err error) {
+				interfaces := make([]dynamic.ResourceInterface, 0)
+				objects := make([]*unstructured.Unstructured, 0)
+				for _, name := range names {
+					interfaces = append(interfaces, dc.Resource(gvr).Namespace("default"))
+					objects = append(objects, testConfigMap(name, "default"))
+				}
+
+				return client.ApplyAndPrune(ctx, interfaces, objects, tc.selector)
+			}
+
+			err := apply(tc.first)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error pruning with selector %q", tc.selector)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed first apply: %s", err)
+			}
+
+			err = apply(tc.second)
+			if err != nil {
+				t.Fatalf("failed second apply: %s", err)
+			}
+
+			list, err := dc.Resource(gvr).Namespace("default").List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatalf("failed listing configmaps: %s", err)
+			}
+
+			actual := make([]string, 0)
+			for _, item := range list.Items {
+				actual = append(actual, item.GetName())
+			}
+
+			sort.Strings(actual)
+
+			assert.Equal(t, tc.expected, actual, "Remaining resources do not match expectations.")
+		})
+	}
+}
+
+func TestApplyAndPruneWithOptions(t *testing.T) {
+	testCases := []struct {
+		name       string
+		first      []*unstructured.Unstructured
+		second     []*unstructured.Unstructured
+		opts       PruneOptions
+		failDelete string
+		expected   []string
+		errors     bool
+	}{
+		{
+			"kind dropped from the manifest",
+			[]*unstructured.Unstructured{testConfigMap("a", "default"), testPruneObject("v1", "Service", "default", "web")},
+			[]*unstructured.Unstructured{testConfigMap("a", "default")},
+			PruneOptions{},
+			"",
+			[]string{"ConfigMap/default/a"},
+			false,
+		},
+		{
+			"everything dropped from the manifest",
+			[]*unstructured.Unstructured{testConfigMap("a", "default"), testPruneObject("v1", "Service", "default", "web")},
+			[]*unstructured.Unstructured{},
+			PruneOptions{},
+			"",
+			[]string{},
+			false,
+		},
+		{
+			"kind outside the default allowlist",
+			[]*unstructured.Unstructured{testConfigMap("a", "default"), testPruneObject("rbac.authorization.k8s.io/v1", "ClusterRole", "", "reader")},
+			[]*unstructured.Unstructured{testConfigMap("a", "default")},
+			PruneOptions{},
+			"",
+			[]string{"ClusterRole//reader", "ConfigMap/default/a"},
+			false,
+		},
+		{
+			"kind outside the default allowlist listed",
+			[]*unstructured.Unstructured{testConfigMap("a", "default"), testPruneObject("rbac.authorization.k8s.io/v1", "ClusterRole", "", "reader")},
+			[]*unstructured.Unstructured{testConfigMap("a", "default")},
+			PruneOptions{Kinds: []schema.GroupVersionKind{{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}}},
+			"",
+			[]string{"ConfigMap/default/a"},
+			false,
+		},
+		{
+			"namespace dropped from the manifest",
+			[]*unstructured.Unstructured{testConfigMap("a", "default"), testConfigMap("b", "other")},
+			[]*unstructured.Unstructured{testConfigMap("a", "default")},
+			PruneOptions{},
+			"",
+			[]string{"ConfigMap/default/a", "ConfigMap/other/b"},
+			false,
+		},
+		{
+			"namespace dropped from the manifest listed",
+			[]*unstructured.Unstructured{testConfigMap("a", "default"), testConfigMap("b", "other")},
+			[]*unstructured.Unstructured{testConfigMap("a", "default")},
+			PruneOptions{Namespaces: []string{"default", "other"}},
+			"",
+			[]string{"ConfigMap/default/a"},
+			false,
+		},
+		{
+			"all namespaces",
+			[]*unstructured.Unstructured{testConfigMap("a", "default"), testConfigMap("b", "other")},
+			[]*unstructured.Unstructured{testConfigMap("a", "default")},
+			PruneOptions{Namespaces: []string{metav1.NamespaceAll}},
+			"",
+			[]string{"ConfigMap/default/a"},
+			false,
+		},
+		{
+			"continue past a failed prune",
+			[]*unstructured.Unstructured{testConfigMap("a", "default"), testConfigMap("b", "default"), testConfigMap("c", "default"), testPruneObject("v1", "Service", "default", "web")},
+			[]*unstructured.Unstructured{testConfigMap("a", "default")},
+			PruneOptions{ContinueOnError: true},
+			"b",
+			[]string{"ConfigMap/default/a", "ConfigMap/default/b"},
+			true,
+		},
+	}
+
+	selector := "app.kubernetes.io/managed-by=utility-client-test"
+	listed := []schema.GroupVersionResource{
+		{Version: "v1", Resource: "configmaps"},
+		{Version: "v1", Resource: "services"},
+		{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), testPruneListKinds())
+			dc.PrependReactor("delete", "*", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				if action.(k8stesting.DeleteAction).GetName() == tc.failDelete {
+					return true, nil, errors.New("stuck")
+				}
+
+				return false, nil, nil
+			})
+
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+			ctx := context.TODO()
+
+			apply := func(objects []*unstructured.Unstructured) (err error) {
+				interfaces := make([]dynamic.ResourceInterface, 0)
+				for _, obj := range objects {
+					ri, err := client.ResourceInterfaceForObject(obj)
+					if err != nil {
+						t.Fatalf("failed getting interface for %s: %s", obj.GetName(), err)
+					}
+
+					interfaces = append(interfaces, ri)
+				}
+
+				return client.ApplyAndPruneWithOptions(ctx, interfaces, objects, selector, tc.opts)
+			}
+
+			err := apply(tc.first)
+			if err != nil {
+				t.Fatalf("failed first apply: %s", err)
+			}
+
+			err = apply(tc.second)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error pruning.")
+			} else if err != nil {
+				t.Fatalf("failed second apply: %s", err)
+			}
+
+			actual := make([]string, 0)
+			for _, gvr := range listed {
+				list, err := dc.Resource(gvr).List(ctx, metav1.ListOptions{})
+				if err != nil {
+					t.Fatalf("failed listing %s: %s", gvr.Resource, err)
+				}
+
+				for _, item := range list.Items {
+					actual = append(actual, fmt.Sprintf("%s/%s/%s", item.GetKind(), item.GetNamespace(), item.GetName()))
+				}
+			}
+
+			sort.Strings(actual)
+
+			assert.Equal(t, tc.expected, actual, "Remaining resources do not match expectations.")
+		})
+	}
+}
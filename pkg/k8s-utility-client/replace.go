@@ -0,0 +1,59 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/retry"
+)
+
+// ReplaceResources  Replaces existing objects wholesale with the ones given, like `kubectl replace`.  Only the live resourceVersion is carried over, so anything not in the new object, labels and annotations included, is cleared.  Unlike ApplyResources, nothing is created: an object that doesn't exist yet is an error satisfying IsNotFound.  Server-side apply is the opposite: it only touches the fields you send, and leaves fields owned by other managers alone.
+func (k *K8sClients) ReplaceResources(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured) (err error) {
+	for i, ri := range interfaces {
+		obj := objects[i]
+
+		err = replace(ctx, ri, obj)
+		if err != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// replace  Replaces a single live object with obj, retrying if another writer gets in between our Get and Update.
+func replace(ctx context.Context, ri dynamic.ResourceInterface, obj *unstructured.Unstructured) (err error) {
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() (err error) {
+		live, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		replacement := obj.DeepCopy()
+		replacement.SetResourceVersion(live.GetResourceVersion())
+
+		err = retryTransient(ctx, func() (err error) {
+			_, err = ri.Update(ctx, replacement, metav1.UpdateOptions{})
+			return err
+		})
+
+		return err
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed replacing %s kind %s", obj.GetName(), obj.GetKind())
+		return err
+	}
+
+	return err
+}
@@ -0,0 +1,88 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+func TestReplaceResources(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exists   bool
+		expected map[string]interface{}
+		errors   bool
+	}{
+		{
+			"existing",
+			true,
+			map[string]interface{}{"foo": "baz"},
+			false,
+		},
+		{
+			"missing",
+			false,
+			nil,
+			true,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := make([]runtime.Object, 0)
+			if tc.exists {
+				live := testConfigMap("replaced", "default")
+				_ = unstructured.SetNestedField(live.Object, "keep me?", "data", "extra")
+				live.SetLabels(map[string]string{"app": "replaced"})
+				live.SetResourceVersion("42")
+				objects = append(objects, live)
+			}
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+			client := &K8sClients{DynamicClient: dc}
+
+			ri := dc.Resource(gvr).Namespace("default")
+			desired := withData(testConfigMap("replaced", "default"), "baz")
+
+			err := client.ReplaceResources(context.TODO(), []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{desired})
+			if tc.errors {
+				assert.True(t, IsNotFound(err), "Expected a NotFound error replacing a missing object.  Got: %s", err)
+
+				_, err = ri.Get(context.TODO(), "replaced", metav1.GetOptions{})
+				assert.True(t, IsNotFound(err), "Replace should not create missing objects.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed replacing resources: %s", err)
+			}
+
+			replaced, err := ri.Get(context.TODO(), "replaced", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed getting replaced object: %s", err)
+			}
+
+			data, _, _ := unstructured.NestedMap(replaced.Object, "data")
+			assert.Equal(t, tc.expected, data, "Fields absent from the replacement should be removed.")
+			assert.Empty(t, replaced.GetLabels(), "Labels absent from the replacement should be removed.")
+			assert.Empty(t, desired.GetResourceVersion(), "The caller's object should not be modified.")
+		})
+	}
+}
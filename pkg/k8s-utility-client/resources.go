@@ -0,0 +1,322 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"strings"
+	"time"
+)
+
+// GetResource  Fetches a single resource by GVK, namespace, and name, without the caller needing to work out the REST mapping.  The namespace is ignored for cluster-scoped kinds.  If the resource doesn't exist, the returned error satisfies apierrors.IsNotFound.
+func (k *K8sClients) GetResource(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string) (obj *unstructured.Unstructured, err error) {
+	ri, err := k.resourceInterface(gvk, namespace)
+	if err != nil {
+		return obj, err
+	}
+
+	obj, err = ri.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting %s kind %s", name, gvk.Kind)
+		return obj, err
+	}
+
+	return obj, err
+}
+
+// GetEventually  Fetches a single resource like GetResource, but polls until it appears, or the timeout fires.  Use it instead of sleeping when something else, such as a controller, is expected to create the resource.  Errors other than NotFound are returned straight away.
+func (k *K8sClients) GetEventually(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string, timeout time.Duration) (obj *unstructured.Unstructured, err error) {
+	ri, err := k.resourceInterface(gvk, namespace)
+	if err != nil {
+		return obj, err
+	}
+
+	err = wait.PollImmediateWithContext(ctx, POLL_INTERVAL, timeout, func(ctx context.Context) (done bool, err error) {
+		obj, err = ri.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+
+			err = errors.Wrapf(err, "failed getting %s kind %s", name, gvk.Kind)
+			return false, err
+		}
+
+		return true, nil
+	})
+
+	if err == wait.ErrWaitTimeout {
+		if ctx.Err() != nil {
+			err = errors.Wrapf(ctx.Err(), "stopped waiting for %s kind %s to appear", name, gvk.Kind)
+			return obj, err
+		}
+
+		err = errors.New(fmt.Sprintf("timed out after %s waiting for %s kind %s to appear", timeout, name, gvk.Kind))
+		return obj, err
+	}
+
+	return obj, err
+}
+
+// GetInto  Fetches a single resource like GetResource, and converts it into the typed object the caller passes in, e.g. a *corev1.ConfigMap, saving the round trip through runtime.DefaultUnstructuredConverter.  into must be a pointer to a struct matching the kind.
+func (k *K8sClients) GetInto(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string, into runtime.Object) (err error) {
+	obj, err := k.GetResource(ctx, gvk, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, into)
+	if err != nil {
+		err = errors.Wrapf(err, "failed converting %s kind %s into %T", name, gvk.Kind, into)
+		return err
+	}
+
+	return err
+}
+
+// ResourceExists  Reports whether a resource exists.  NotFound is not an error, it just means false.  Any other failure to Get the resource is returned.
+func (k *K8sClients) ResourceExists(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string) (exists bool, err error) {
+	_, err = k.GetResource(ctx, gvk, namespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, err
+}
+
+// PatchResource  Patches a single resource by GVK, namespace, and name.  Supports strategic merge, JSON merge, and JSON (RFC 6902) patches.  A patch only touches the fields it names, so there's no read-modify-write race with other writers.  Strategic merge patches also honor list-merge semantics, e.g. merging a container's env rather than replacing it.  Strategic merge only works on built-in kinds; use a merge patch for custom resources.
+func (k *K8sClients) PatchResource(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string, patchType types.PatchType, data []byte) (obj *unstructured.Unstructured, err error) {
+	switch patchType {
+	case types.StrategicMergePatchType, types.MergePatchType, types.JSONPatchType:
+	default:
+		err = errors.New(fmt.Sprintf("unsupported patch type %s", patchType))
+		return obj, err
+	}
+
+	ri, err := k.resourceInterface(gvk, namespace)
+	if err != nil {
+		return obj, err
+	}
+
+	obj, err = ri.Patch(ctx, name, patchType, data, metav1.PatchOptions{})
+	if err != nil {
+		err = errors.Wrapf(err, "failed patching %s kind %s", name, gvk.Kind)
+		return obj, err
+	}
+
+	return obj, err
+}
+
+// JSONPatchResource  Patches a single resource with an RFC 6902 JSON patch, a list of add, remove, replace, move, copy, and test operations.  Unlike merge patches, it can change a single element of a list, e.g. `[{"op": "replace", "path": "/spec/template/spec/containers/0/image", "value": "nginx:1.25"}]`.  The patch is checked to be a JSON array before it's sent.
+func (k *K8sClients) JSONPatchResource(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string, patch []byte) (obj *unstructured.Unstructured, err error) {
+	var operations []map[string]interface{}
+	err = json.Unmarshal(patch, &operations)
+	if err != nil {
+		err = errors.Wrapf(err, "json patch for %s kind %s is not a JSON array of operations", name, gvk.Kind)
+		return obj, err
+	}
+
+	return k.PatchResource(ctx, gvk, namespace, name, types.JSONPatchType, patch)
+}
+
+// ListResources  Lists resources of the given GVK, passing the label and field selectors in opts through to the server.  For namespaced kinds, an empty namespace lists across all namespaces.  The namespace is ignored for cluster-scoped kinds.
+func (k *K8sClients) ListResources(ctx context.Context, gvk schema.GroupVersionKind, namespace string, opts metav1.ListOptions) (list *unstructured.UnstructuredList, err error) {
+	ri, err := k.collectionInterface(gvk, namespace)
+	if err != nil {
+		return list, err
+	}
+
+	list, err = ri.List(ctx, opts)
+	if err != nil {
+		err = errors.Wrapf(err, "failed listing kind %s", gvk.Kind)
+		return list, err
+	}
+
+	return list, err
+}
+
+// WatchResources  Watches resources of the given GVK for Added, Modified, and Deleted events, with the label and field selectors in opts.  Namespace handling is the same as ListResources.  The watch ends when ctx is cancelled, or when the caller calls Stop() on it.
+func (k *K8sClients) WatchResources(ctx context.Context, gvk schema.GroupVersionKind, namespace string, opts metav1.ListOptions) (watcher watch.Interface, err error) {
+	ri, err := k.collectionInterface(gvk, namespace)
+	if err != nil {
+		return watcher, err
+	}
+
+	watcher, err = ri.Watch(ctx, opts)
+	if err != nil {
+		err = errors.Wrapf(err, "failed watching kind %s", gvk.Kind)
+		return watcher, err
+	}
+
+	return watcher, err
+}
+
+// collectionInterface  Resolves a GVK to a dynamic.ResourceInterface suitable for List and Watch.  For namespaced kinds an empty namespace means all namespaces.  The namespace is ignored for cluster-scoped kinds.
+func (k *K8sClients) collectionInterface(gvk schema.GroupVersionKind, namespace string) (ri dynamic.ResourceInterface, err error) {
+	mapping, err := k.restMapping(gvk)
+	if err != nil {
+		return ri, err
+	}
+
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace && namespace != "" {
+		ri = k.DynamicClient.Resource(mapping.Resource).Namespace(namespace)
+		return ri, err
+	}
+
+	ri = k.DynamicClient.Resource(mapping.Resource)
+
+	return ri, err
+}
+
+// Mapper  Returns the RESTMapper used to resolve GroupVersionKinds to GroupVersionResources, building and caching it on first use.  The mapper discovers lazily, and caches what it discovers, so it's cheap to call repeatedly.
+func (k *K8sClients) Mapper() (mapper meta.RESTMapper, err error) {
+	if k.RESTMapper == nil {
+		if k.ClientSet == nil {
+			err = errors.New("no k8s clientset from which to build a rest mapper")
+			return mapper, err
+		}
+
+		k.RESTMapper = restmapper.NewDeferredDiscoveryRESTMapper(k.cachedDiscovery())
+	}
+
+	return k.RESTMapper, err
+}
+
+// resetMapper  Forgets everything the RESTMapper has discovered, so kinds created since, such as those defined by a newly established CRD, can be resolved.
+func (k *K8sClients) resetMapper() {
+	if resettable, ok := k.RESTMapper.(meta.ResettableRESTMapper); ok {
+		resettable.Reset()
+	}
+}
+
+// restMapping  Resolves a GVK to its REST mapping.
+func (k *K8sClients) restMapping(gvk schema.GroupVersionKind) (mapping *meta.RESTMapping, err error) {
+	mapper, err := k.Mapper()
+	if err != nil {
+		return mapping, err
+	}
+
+	mapping, err = mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating rest mapping for %s", gvk.String())
+		return mapping, err
+	}
+
+	return mapping, err
+}
+
+// resourceInterface  Resolves a GVK to a dynamic.ResourceInterface.  Namespaced kinds get the given namespace, or "default" if it's empty.  The namespace is ignored for cluster-scoped kinds.
+func (k *K8sClients) resourceInterface(gvk schema.GroupVersionKind, namespace string) (ri dynamic.ResourceInterface, err error) {
+	mapping, err := k.restMapping(gvk)
+	if err != nil {
+		return ri, err
+	}
+
+	ri = k.mappingInterface(mapping, namespace)
+
+	return ri, err
+}
+
+// ResourceInterfaceForObject  Resolves an object's kind against the cluster, and returns the dynamic.ResourceInterface through which it can be created, fetched, or deleted.  Namespaced objects without a namespace are put into "default", just as when they're loaded from yaml.  The namespace is ignored for cluster-scoped kinds.
+func (k *K8sClients) ResourceInterfaceForObject(obj *unstructured.Unstructured) (ri dynamic.ResourceInterface, err error) {
+	mapping, err := k.restMapping(obj.GroupVersionKind())
+	if err != nil {
+		return ri, err
+	}
+
+	ri = k.objectInterface(mapping, obj, "")
+
+	return ri, err
+}
+
+// ResourceInterfaceForGVR  Returns the dynamic.ResourceInterface for a resource written as "group/version/resource", or "version/resource" for the core group, e.g. "apps/v1/deployments" or "v1/configmaps".  Nothing is looked up, so there's no discovery, but nothing is checked either.  The resource must be the plural, lower case name, and since the scope isn't known, namespace is taken as given: leave it empty for cluster-scoped resources, or to list across all namespaces.
+func (k *K8sClients) ResourceInterfaceForGVR(gvrString string, namespace string) (ri dynamic.ResourceInterface, err error) {
+	gvr, err := parseGVR(gvrString)
+	if err != nil {
+		return ri, err
+	}
+
+	if namespace == "" {
+		ri = k.DynamicClient.Resource(gvr)
+		return ri, err
+	}
+
+	ri = k.DynamicClient.Resource(gvr).Namespace(namespace)
+
+	return ri, err
+}
+
+// parseGVR  Splits "group/version/resource", or "version/resource" for the core group, into a schema.GroupVersionResource.
+func parseGVR(gvrString string) (gvr schema.GroupVersionResource, err error) {
+	parts := strings.Split(gvrString, "/")
+	for _, part := range parts {
+		if part == "" {
+			err = errors.New(fmt.Sprintf("malformed resource %q.  Expected group/version/resource or version/resource", gvrString))
+			return gvr, err
+		}
+	}
+
+	switch len(parts) {
+	case 2:
+		gvr = schema.GroupVersionResource{Version: parts[0], Resource: parts[1]}
+	case 3:
+		gvr = schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}
+	default:
+		err = errors.New(fmt.Sprintf("malformed resource %q.  Expected group/version/resource or version/resource", gvrString))
+	}
+
+	return gvr, err
+}
+
+// objectInterface  Returns the dynamic.ResourceInterface for an object whose kind has already been mapped.  For namespaced kinds, the object's namespace is replaced by namespace if that's non-empty, and set to "default" if it's still empty.
+func (k *K8sClients) objectInterface(mapping *meta.RESTMapping, obj *unstructured.Unstructured, namespace string) (ri dynamic.ResourceInterface) {
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if namespace != "" {
+			obj.SetNamespace(namespace)
+		}
+
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(DEFAULT_NAMESPACE)
+		}
+	}
+
+	return k.mappingInterface(mapping, obj.GetNamespace())
+}
+
+// mappingInterface  Returns the dynamic.ResourceInterface for a mapped kind.  Namespaced kinds get the given namespace, or "default" if it's empty.  The namespace is ignored for cluster-scoped kinds.
+func (k *K8sClients) mappingInterface(mapping *meta.RESTMapping, namespace string) (ri dynamic.ResourceInterface) {
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if namespace == "" {
+			namespace = DEFAULT_NAMESPACE
+		}
+
+		return k.DynamicClient.Resource(mapping.Resource).Namespace(namespace)
+	}
+
+	return k.DynamicClient.Resource(mapping.Resource)
+}
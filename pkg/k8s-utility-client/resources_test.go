@@ -0,0 +1,828 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestGetResource(t *testing.T) {
+	testCases := []struct {
+		name      string
+		namespace string
+		resource  string
+		notFound  bool
+	}{
+		{
+			"present",
+			"default",
+			"present",
+			false,
+		},
+		{
+			"defaulted namespace",
+			"",
+			"present",
+			false,
+		},
+		{
+			"absent",
+			"default",
+			"absent",
+			true,
+		},
+	}
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("present", "default"))
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			obj, err := client.GetResource(context.TODO(), gvk, tc.namespace, tc.resource)
+			if tc.notFound {
+				assert.True(t, apierrors.IsNotFound(err), "Expected a NotFound error.  Got: %s", err)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed getting resource: %s", err)
+			}
+
+			assert.Equal(t, tc.resource, obj.GetName(), "Fetched resource name does not match.")
+			assert.Equal(t, "default", obj.GetNamespace(), "Fetched resource namespace does not match.")
+		})
+	}
+}
+
+func TestGetEventually(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exists   bool
+		misses   int
+		failWith error
+		attempts int
+		errors   bool
+	}{
+		{
+			"already there",
+			true,
+			0,
+			nil,
+			1,
+			false,
+		},
+		{
+			"appears after a miss",
+			true,
+			1,
+			nil,
+			2,
+			false,
+		},
+		{
+			"never appears",
+			false,
+			0,
+			nil,
+			0,
+			true,
+		},
+		{
+			"forbidden",
+			true,
+			100,
+			apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "eventual", fmt.Errorf("no")),
+			1,
+			true,
+		},
+	}
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	gr := schema.GroupResource{Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := make([]runtime.Object, 0)
+			if tc.exists {
+				objects = append(objects, testConfigMap("eventual", "default"))
+			}
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+
+			// a lagging cache, or a controller yet to get round to it, looks like NotFound for a while
+			attempts := 0
+			dc.PrependReactor("get", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				attempts++
+				if attempts <= tc.misses {
+					if tc.failWith != nil {
+						return true, nil, tc.failWith
+					}
+
+					return true, nil, apierrors.NewNotFound(gr, "eventual")
+				}
+
+				return false, nil, nil
+			})
+
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			// long enough for a miss, short enough not to hang around for one that never comes
+			timeout := 3 * time.Second
+			if !tc.exists {
+				timeout = 100 * time.Millisecond
+			}
+
+			obj, err := client.GetEventually(context.TODO(), gvk, "default", "eventual", timeout)
+			// how many polls fit in before a timeout is up to the timing, so only count when it's certain
+			if tc.attempts > 0 {
+				assert.Equal(t, tc.attempts, attempts, "Unexpected number of attempts.")
+			}
+
+			if tc.errors {
+				assert.Error(t, err, "Expected an error getting the resource.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed getting resource eventually: %s", err)
+			}
+
+			assert.Equal(t, "eventual", obj.GetName(), "Resource name does not match expectations.")
+		})
+	}
+}
+
+func TestGetInto(t *testing.T) {
+	testCases := []struct {
+		name     string
+		resource string
+		errors   bool
+	}{
+		{
+			"existing",
+			"typed",
+			false,
+		},
+		{
+			"missing",
+			"absent",
+			true,
+		},
+	}
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("typed", "default"))
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			var configMap corev1.ConfigMap
+			err := client.GetInto(context.TODO(), gvk, "default", tc.resource, &configMap)
+			if tc.errors {
+				assert.True(t, apierrors.IsNotFound(err), "Expected a NotFound error.  Got: %s", err)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed getting resource: %s", err)
+			}
+
+			assert.Equal(t, "typed", configMap.Name, "ConfigMap name does not match expectations.")
+			assert.Equal(t, "default", configMap.Namespace, "ConfigMap namespace does not match expectations.")
+			assert.Equal(t, map[string]string{"foo": "bar"}, configMap.Data, "ConfigMap data does not match expectations.")
+		})
+	}
+}
+
+func TestResourceExists(t *testing.T) {
+	testCases := []struct {
+		name     string
+		resource string
+		forbid   bool
+		exists   bool
+		errors   bool
+	}{
+		{
+			"present",
+			"present",
+			false,
+			true,
+			false,
+		},
+		{
+			"absent",
+			"absent",
+			false,
+			false,
+			false,
+		},
+		{
+			"forbidden",
+			"present",
+			true,
+			false,
+			true,
+		},
+	}
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("present", "default"))
+			if tc.forbid {
+				dc.PrependReactor("get", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+					return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, tc.resource, fmt.Errorf("no access"))
+				})
+			}
+
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			exists, err := client.ResourceExists(context.TODO(), gvk, "default", tc.resource)
+			if tc.errors {
+				assert.True(t, apierrors.IsForbidden(err), "Expected a Forbidden error.  Got: %s", err)
+				assert.False(t, exists, "Resource reported as existing despite an error.")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error checking existence.")
+			assert.Equal(t, tc.exists, exists, "Existence does not match expectations.")
+		})
+	}
+}
+
+func TestGetResourceLive(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+	}{
+		{
+			"configmap",
+			"test_fixtures/configmap.yaml",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Creating resources in k8s.\n")
+			err = client.ApplyResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed to apply resources: %s", err)
+			}
+
+			for _, obj := range objects {
+				o, err := client.GetResource(ctx, obj.GroupVersionKind(), obj.GetNamespace(), obj.GetName())
+				if err != nil {
+					t.Errorf("failed getting resource %s kind %s: %s", obj.GetName(), obj.GetKind(), err)
+					continue
+				}
+
+				assert.Equal(t, obj.GetName(), o.GetName(), "Fetched Resource name doesn't match expectation.")
+				assert.Equal(t, obj.GetKind(), o.GetKind(), "Fetched Resource Kind does not match expectations.")
+			}
+
+			fmt.Printf("Cleaning up resources in k8s.\n")
+			err = client.DeleteResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Errorf("failed deleting resources: %s", err)
+			}
+		})
+	}
+}
+
+func TestListResources(t *testing.T) {
+	testCases := []struct {
+		name      string
+		namespace string
+		selector  string
+		expected  []string
+	}{
+		{
+			"everything",
+			"",
+			"",
+			[]string{"blue", "green", "red"},
+		},
+		{
+			"one namespace",
+			"default",
+			"",
+			[]string{"blue", "green"},
+		},
+		{
+			"label selector",
+			"default",
+			"color=green",
+			[]string{"green"},
+		},
+		{
+			"label selector across namespaces",
+			"",
+			"tier=front",
+			[]string{"green", "red"},
+		},
+	}
+
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			blue := testConfigMap("blue", "default")
+			blue.SetLabels(map[string]string{"color": "blue", "tier": "back"})
+			green := testConfigMap("green", "default")
+			green.SetLabels(map[string]string{"color": "green", "tier": "front"})
+			red := testConfigMap("red", "other")
+			red.SetLabels(map[string]string{"color": "red", "tier": "front"})
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), blue, green, red)
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			list, err := client.ListResources(context.TODO(), gvk, tc.namespace, metav1.ListOptions{LabelSelector: tc.selector})
+			if err != nil {
+				t.Fatalf("failed listing resources: %s", err)
+			}
+
+			actual := make([]string, 0)
+			for _, item := range list.Items {
+				actual = append(actual, item.GetName())
+			}
+
+			sort.Strings(actual)
+
+			assert.Equal(t, tc.expected, actual, "Listed resources do not match expectations.")
+		})
+	}
+}
+
+func TestWatchResources(t *testing.T) {
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("existing", "default"))
+	client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	watcher, err := client.WatchResources(ctx, gvk, "default", metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed starting watch: %s", err)
+	}
+
+	defer watcher.Stop()
+
+	_, err = dc.Resource(gvr).Namespace("default").Create(ctx, testConfigMap("watched", "default"), metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed creating resource: %s", err)
+	}
+
+	select {
+	case event := <-watcher.ResultChan():
+		assert.Equal(t, watch.Added, event.Type, "Unexpected event type.")
+		if obj, ok := event.Object.(*unstructured.Unstructured); assert.True(t, ok, "Event object is not Unstructured.") {
+			assert.Equal(t, "watched", obj.GetName(), "Event is for the wrong object.")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("timed out waiting for an Added event")
+	}
+}
+
+func TestMapper(t *testing.T) {
+	testCases := []struct {
+		name       string
+		gvk        schema.GroupVersionKind
+		resource   schema.GroupVersionResource
+		namespaced bool
+	}{
+		{
+			"configmap",
+			schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			true,
+		},
+		{
+			"namespace",
+			schema.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+			schema.GroupVersionResource{Version: "v1", Resource: "namespaces"},
+			false,
+		},
+	}
+
+	client, err := NewK8sClients()
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	mapper, err := client.Mapper()
+	if err != nil {
+		t.Fatalf("failed getting rest mapper: %s", err)
+	}
+
+	assert.Equal(t, client.RESTMapper, mapper, "Mapper() did not return the exposed RESTMapper.")
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mapping, err := mapper.RESTMapping(tc.gvk.GroupKind(), tc.gvk.Version)
+			if err != nil {
+				t.Fatalf("failed resolving %s: %s", tc.gvk.String(), err)
+			}
+
+			assert.Equal(t, tc.resource, mapping.Resource, "Resolved resource does not match.")
+			assert.Equal(t, tc.namespaced, mapping.Scope.Name() == meta.RESTScopeNameNamespace, "Resolved scope does not match.")
+		})
+	}
+}
+
+func TestMapperWithoutClientSet(t *testing.T) {
+	client := &K8sClients{}
+
+	_, err := client.Mapper()
+	assert.Error(t, err, "Expected an error building a mapper without a clientset.")
+}
+
+func TestPatchResource(t *testing.T) {
+	testCases := []struct {
+		name      string
+		patchType types.PatchType
+		data      string
+		replicas  int64
+		errors    bool
+	}{
+		{
+			"merge patch",
+			types.MergePatchType,
+			`{"spec":{"replicas":4}}`,
+			4,
+			false,
+		},
+		{
+			"json patch",
+			types.JSONPatchType,
+			`[{"op":"replace","path":"/spec/replicas","value":5}]`,
+			5,
+			false,
+		},
+		{
+			"unsupported patch type",
+			types.ApplyPatchType,
+			`{"spec":{"replicas":6}}`,
+			0,
+			true,
+		},
+	}
+
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testDeployment("patched", 1, 1))
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			obj, err := client.PatchResource(context.TODO(), gvk, "default", "patched", tc.patchType, []byte(tc.data))
+			if tc.errors {
+				assert.Error(t, err, "Expected an error for patch type %s", tc.patchType)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed patching resource: %s", err)
+			}
+
+			replicas, _, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+			if err != nil {
+				t.Fatalf("failed reading replicas: %s", err)
+			}
+
+			assert.Equal(t, tc.replicas, replicas, "Patch did not set the replica count.")
+		})
+	}
+}
+
+func TestJSONPatchResource(t *testing.T) {
+	testCases := []struct {
+		name     string
+		patch    string
+		replicas int64
+		errors   bool
+	}{
+		{
+			"replace",
+			`[{"op":"replace","path":"/spec/replicas","value":3}]`,
+			3,
+			false,
+		},
+		{
+			"test then replace",
+			`[{"op":"test","path":"/spec/replicas","value":1},{"op":"replace","path":"/spec/replicas","value":2}]`,
+			2,
+			false,
+		},
+		{
+			"failed test",
+			`[{"op":"test","path":"/spec/replicas","value":7},{"op":"replace","path":"/spec/replicas","value":2}]`,
+			0,
+			true,
+		},
+		{
+			"not an array",
+			`{"op":"replace","path":"/spec/replicas","value":3}`,
+			0,
+			true,
+		},
+		{
+			"not json",
+			`replicas: 3`,
+			0,
+			true,
+		},
+	}
+
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testDeployment("patched", 1, 1))
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			obj, err := client.JSONPatchResource(context.TODO(), gvk, "default", "patched", []byte(tc.patch))
+			if tc.errors {
+				assert.Error(t, err, "Expected an error for patch %s", tc.patch)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed patching resource: %s", err)
+			}
+
+			replicas, _, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+			if err != nil {
+				t.Fatalf("failed reading replicas: %s", err)
+			}
+
+			assert.Equal(t, tc.replicas, replicas, "Patch did not set the replica count.")
+		})
+	}
+}
+
+func TestPatchResourceStrategicMerge(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+		replicas int64
+	}{
+		{
+			"deployment replicas",
+			"test_fixtures/resources.yaml",
+			2,
+		},
+	}
+
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Creating resources in k8s.\n")
+			err = client.ApplyResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed to apply resources: %s", err)
+			}
+
+			defer func() {
+				fmt.Printf("Cleaning up resources in k8s.\n")
+				err = client.DeleteResources(ctx, interfaces, objects)
+				if err != nil {
+					t.Errorf("failed deleting resources: %s", err)
+				}
+			}()
+
+			patch := fmt.Sprintf(`{"spec":{"replicas":%d}}`, tc.replicas)
+
+			obj, err := client.PatchResource(ctx, gvk, "default", "nginx", types.StrategicMergePatchType, []byte(patch))
+			if err != nil {
+				t.Fatalf("failed patching deployment: %s", err)
+			}
+
+			replicas, _, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+			if err != nil {
+				t.Fatalf("failed reading replicas: %s", err)
+			}
+
+			assert.Equal(t, tc.replicas, replicas, "Strategic merge patch did not set the replica count.")
+
+			// the rest of the spec must survive the patch
+			containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+			assert.Equal(t, 1, len(containers), "Strategic merge patch clobbered the pod template.")
+		})
+	}
+}
+
+func TestResourceInterfaceForObject(t *testing.T) {
+	testCases := []struct {
+		name      string
+		object    *unstructured.Unstructured
+		gvr       schema.GroupVersionResource
+		namespace string
+		errors    bool
+	}{
+		{
+			"namespaced",
+			testConfigMap("mapped", "apps"),
+			schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			"apps",
+			false,
+		},
+		{
+			"namespaced without a namespace",
+			testConfigMap("mapped", ""),
+			schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			"default",
+			false,
+		},
+		{
+			"cluster-scoped",
+			&unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "rbac.authorization.k8s.io/v1",
+				"kind":       "ClusterRole",
+				"metadata":   map[string]interface{}{"name": "mapped"},
+			}},
+			schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+			"",
+			false,
+		},
+		{
+			"unknown kind",
+			&unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "example.com/v1",
+				"kind":       "Gizmo",
+				"metadata":   map[string]interface{}{"name": "mapped"},
+			}},
+			schema.GroupVersionResource{},
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			ri, err := client.ResourceInterfaceForObject(tc.object)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error mapping an unknown kind.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed mapping object: %s", err)
+			}
+
+			assert.Equal(t, tc.namespace, tc.object.GetNamespace(), "Object namespace does not match expectations.")
+
+			_, err = ri.Create(context.TODO(), tc.object, metav1.CreateOptions{})
+			if err != nil {
+				t.Fatalf("failed creating object: %s", err)
+			}
+
+			// the object should land exactly where a client built by hand for the expected resource and namespace would look for it
+			_, err = dc.Resource(tc.gvr).Namespace(tc.namespace).Get(context.TODO(), "mapped", metav1.GetOptions{})
+			assert.NoError(t, err, "Object was not created where expected.")
+		})
+	}
+}
+
+func TestResourceInterfaceForGVR(t *testing.T) {
+	testCases := []struct {
+		name      string
+		gvr       string
+		namespace string
+		object    string
+		expected  schema.GroupVersionResource
+		errors    bool
+	}{
+		{
+			"core group",
+			"v1/configmaps",
+			"default",
+			"gvr-config",
+			schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			false,
+		},
+		{
+			"named group",
+			"apps/v1/deployments",
+			"default",
+			"gvr-deployment",
+			schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"},
+			false,
+		},
+		{
+			"resource only",
+			"configmaps",
+			"default",
+			"",
+			schema.GroupVersionResource{},
+			true,
+		},
+		{
+			"too many parts",
+			"apps/v1/deployments/scale",
+			"default",
+			"",
+			schema.GroupVersionResource{},
+			true,
+		},
+		{
+			"empty part",
+			"apps//deployments",
+			"default",
+			"",
+			schema.GroupVersionResource{},
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gvr, err := parseGVR(tc.gvr)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error parsing %q.", tc.gvr)
+
+				_, err = (&K8sClients{}).ResourceInterfaceForGVR(tc.gvr, tc.namespace)
+				assert.Error(t, err, "Expected an error getting an interface for %q.", tc.gvr)
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed parsing %q: %s", tc.gvr, err)
+			}
+
+			assert.Equal(t, tc.expected, gvr, "Parsed resource does not match expectations.")
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("gvr-config", "default"), testDeployment("gvr-deployment", 1, 1))
+			client := &K8sClients{DynamicClient: dc}
+
+			ri, err := client.ResourceInterfaceForGVR(tc.gvr, tc.namespace)
+			if err != nil {
+				t.Fatalf("failed getting interface for %q: %s", tc.gvr, err)
+			}
+
+			obj, err := ri.Get(context.TODO(), tc.object, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed getting %s through interface for %q: %s", tc.object, tc.gvr, err)
+			}
+
+			assert.Equal(t, tc.object, obj.GetName(), "Fetched object does not match expectations.")
+		})
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"time"
+)
+
+// transientBackoff  How writes that fail with a transient error are retried.  Five attempts over roughly three seconds, which covers an API server restarting behind a load balancer.
+var transientBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 200 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+// isTransient  Reports whether an error from the API server is likely to go away if we try again: a server timeout, an unavailable server, or being told to slow down.
+func isTransient(err error) bool {
+	return apierrors.IsServerTimeout(err) || apierrors.IsServiceUnavailable(err) || apierrors.IsTooManyRequests(err)
+}
+
+// retryTransient  Runs fn, retrying with exponential backoff for as long as it fails with a transient error.  Any other error is returned immediately.  If the context is cancelled while waiting between attempts, the last error is returned.
+func retryTransient(ctx context.Context, fn func() error) (err error) {
+	backoff := transientBackoff
+
+	for {
+		err = fn()
+		if err == nil || !isTransient(err) || backoff.Steps <= 1 {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff.Step()):
+		}
+	}
+}
+
+// retryCreate  Runs a create like retryTransient.  A create that times out, or gets a 5xx, may still have gone through on the server, in which case the retry fails with AlreadyExists.  existed reports exactly that, with a nil error, so the caller can carry on as though the object had been there all along, rather than failing a write that worked.  AlreadyExists on the first attempt is still an error.
+func retryCreate(ctx context.Context, fn func() error) (existed bool, err error) {
+	retried := false
+
+	err = retryTransient(ctx, func() (err error) {
+		err = fn()
+		if isTransient(err) {
+			retried = true
+		}
+
+		return err
+	})
+
+	if retried && apierrors.IsAlreadyExists(err) {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// retryDelete  Runs a delete like retryTransient.  A delete that times out, or gets a 5xx, may still have gone through on the server, in which case the retry fails with NotFound.  That's reported as success, since the object is gone as asked.  NotFound on the first attempt is still an error.
+func retryDelete(ctx context.Context, fn func() error) (err error) {
+	retried := false
+
+	err = retryTransient(ctx, func() (err error) {
+		err = fn()
+		if isTransient(err) {
+			retried = true
+		}
+
+		return err
+	})
+
+	if retried && apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,378 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+	"time"
+)
+
+func TestApplyResourcesRetriesTransientErrors(t *testing.T) {
+	// don't make the tests wait out the real backoff
+	defer func(b wait.Backoff) { transientBackoff = b }(transientBackoff)
+	transientBackoff.Duration = time.Millisecond
+
+	configMaps := schema.GroupResource{Resource: "configmaps"}
+
+	testCases := []struct {
+		name     string
+		failures int
+		failWith error
+		attempts int
+		errors   bool
+	}{
+		{
+			"no failures",
+			0,
+			nil,
+			1,
+			false,
+		},
+		{
+			"service unavailable twice",
+			2,
+			apierrors.NewServiceUnavailable("apiserver is restarting"),
+			3,
+			false,
+		},
+		{
+			"server timeout twice",
+			2,
+			apierrors.NewServerTimeout(configMaps, "create", 1),
+			3,
+			false,
+		},
+		{
+			"too many requests twice",
+			2,
+			apierrors.NewTooManyRequests("slow down", 1),
+			3,
+			false,
+		},
+		{
+			"unavailable for good",
+			100,
+			apierrors.NewServiceUnavailable("apiserver is gone"),
+			transientBackoff.Steps,
+			true,
+		},
+		{
+			"forbidden",
+			100,
+			apierrors.NewForbidden(configMaps, "foo", errors.New("no")),
+			1,
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+			attempts := 0
+			dc.PrependReactor("create", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				attempts++
+				if attempts <= tc.failures {
+					return true, nil, tc.failWith
+				}
+
+				return false, nil, nil
+			})
+
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			obj := testConfigMap("foo", "default")
+			ri, err := client.ResourceInterfaceForObject(obj)
+			if err != nil {
+				t.Fatalf("failed getting resource interface: %s", err)
+			}
+
+			err = client.ApplyResources(context.TODO(), []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{obj})
+			assert.Equal(t, tc.attempts, attempts, "Unexpected number of attempts.")
+
+			if tc.errors {
+				assert.Error(t, err, "Expected the apply to fail.")
+				return
+			}
+
+			assert.NoError(t, err, "Apply should have succeeded after retrying.")
+
+			_, err = ri.Get(context.TODO(), "foo", metav1.GetOptions{})
+			assert.NoError(t, err, "ConfigMap was not created.")
+		})
+	}
+}
+
+func TestRetryTransientCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	attempts := 0
+	err := retryTransient(ctx, func() error {
+		attempts++
+		cancel()
+
+		return apierrors.NewServiceUnavailable("apiserver is restarting")
+	})
+
+	assert.True(t, apierrors.IsServiceUnavailable(err), "Expected the last error back, got: %s", err)
+	assert.Equal(t, 1, attempts, "Should stop retrying once the context is cancelled.")
+}
+
+func TestApplyResourcesCreateTimesOutButPersists(t *testing.T) {
+	// don't make the tests wait out the real backoff
+	defer func(b wait.Backoff) { transientBackoff = b }(transientBackoff)
+	transientBackoff.Duration = time.Millisecond
+
+	configMaps := schema.GroupResource{Resource: "configmaps"}
+
+	testCases := []struct {
+		name     string
+		opts     ApplyOptions
+		persist  string
+		failWith error
+		action   ApplyAction
+		errors   bool
+	}{
+		{
+			"create went through",
+			ApplyOptions{},
+			"ours",
+			apierrors.NewServerTimeout(configMaps, "create", 1),
+			APPLY_CREATED,
+			false,
+		},
+		{
+			"create went through with three-way merge",
+			ApplyOptions{ThreeWayMerge: true},
+			"ours",
+			apierrors.NewServerTimeout(configMaps, "create", 1),
+			APPLY_CREATED,
+			false,
+		},
+		{
+			"create went through after a 503",
+			ApplyOptions{},
+			"ours",
+			apierrors.NewServiceUnavailable("apiserver is restarting"),
+			APPLY_CREATED,
+			false,
+		},
+		{
+			"someone else created it meanwhile",
+			ApplyOptions{},
+			"theirs",
+			apierrors.NewServerTimeout(configMaps, "create", 1),
+			"",
+			false,
+		},
+		{
+			"already exists without a retry",
+			ApplyOptions{},
+			"",
+			apierrors.NewAlreadyExists(configMaps, "foo"),
+			"",
+			true,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+			// the first create fails, but the object lands in the cluster all the same
+			creates := 0
+			dc.PrependReactor("create", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				creates++
+				if creates > 1 {
+					return false, nil, nil
+				}
+
+				switch tc.persist {
+				case "ours":
+					err = dc.Tracker().Create(gvr, action.(k8stesting.CreateAction).GetObject().DeepCopyObject(), "default")
+				case "theirs":
+					err = dc.Tracker().Create(gvr, withData(testConfigMap("foo", "default"), "theirs"), "default")
+				}
+				if err != nil {
+					return true, nil, err
+				}
+
+				return true, nil, tc.failWith
+			})
+
+			client := &K8sClients{DynamicClient: dc}
+			ri := dc.Resource(gvr).Namespace("default")
+
+			result, err := client.ApplyResourcesWithResult(context.TODO(), []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{testConfigMap("foo", "default")}, tc.opts)
+			if tc.errors {
+				if assert.Error(t, err, "Expected the apply to fail.") {
+					assert.True(t, apierrors.IsAlreadyExists(errors.Cause(err)), "Expected AlreadyExists, got: %s", err)
+				}
+				assert.Equal(t, 1, creates, "Should not retry a create that was never transient.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("apply failed on a create that went through: %s", err)
+			}
+
+			if tc.action != "" {
+				assert.Equal(t, tc.action, result.Objects[0].Action, "Unexpected outcome of the apply.")
+			}
+
+			live, err := ri.Get(context.TODO(), "foo", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed getting configmap: %s", err)
+			}
+
+			data, _, _ := unstructured.NestedString(live.Object, "data", "foo")
+			assert.Equal(t, "bar", data, "Live object does not have the desired content.")
+		})
+	}
+}
+
+func TestApplyResourcesRetriesTransientUpdates(t *testing.T) {
+	// don't make the tests wait out the real backoff
+	defer func(b wait.Backoff) { transientBackoff = b }(transientBackoff)
+	transientBackoff.Duration = time.Millisecond
+
+	dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), withData(testConfigMap("foo", "default"), "old"))
+
+	attempts := 0
+	dc.PrependReactor("update", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+		attempts++
+		if attempts <= 2 {
+			return true, nil, apierrors.NewServiceUnavailable("apiserver is restarting")
+		}
+
+		return false, nil, nil
+	})
+
+	client := &K8sClients{DynamicClient: dc}
+	ri := dc.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}).Namespace("default")
+
+	err := client.ApplyResources(context.TODO(), []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{testConfigMap("foo", "default")})
+	assert.NoError(t, err, "Apply should have succeeded after retrying.")
+	assert.Equal(t, 3, attempts, "Unexpected number of attempts.")
+
+	live, err := ri.Get(context.TODO(), "foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed getting configmap: %s", err)
+	}
+
+	data, _, _ := unstructured.NestedString(live.Object, "data", "foo")
+	assert.Equal(t, "bar", data, "ConfigMap was not updated.")
+}
+
+func TestDeleteRetriesTransientErrors(t *testing.T) {
+	// don't make the tests wait out the real backoff
+	defer func(b wait.Backoff) { transientBackoff = b }(transientBackoff)
+	transientBackoff.Duration = time.Millisecond
+
+	configMaps := schema.GroupResource{Resource: "configmaps"}
+
+	testCases := []struct {
+		name     string
+		failures int
+		failWith error
+		persist  bool
+		object   bool
+		attempts int
+	}{
+		{
+			"fails twice",
+			2,
+			apierrors.NewServiceUnavailable("apiserver is restarting"),
+			false,
+			false,
+			3,
+		},
+		{
+			"fails twice deleting an object",
+			2,
+			apierrors.NewServiceUnavailable("apiserver is restarting"),
+			false,
+			true,
+			3,
+		},
+		{
+			"delete went through",
+			1,
+			apierrors.NewServerTimeout(configMaps, "delete", 1),
+			true,
+			false,
+			2,
+		},
+		{
+			"delete went through deleting an object",
+			1,
+			apierrors.NewServerTimeout(configMaps, "delete", 1),
+			true,
+			true,
+			2,
+		},
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("foo", "default"))
+
+			attempts := 0
+			dc.PrependReactor("delete", "configmaps", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+				attempts++
+				if attempts > tc.failures {
+					return false, nil, nil
+				}
+
+				// the delete lands in the cluster, but the caller is told otherwise
+				if tc.persist && attempts == 1 {
+					err = dc.Tracker().Delete(gvr, "default", "foo")
+					if err != nil {
+						return true, nil, err
+					}
+				}
+
+				return true, nil, tc.failWith
+			})
+
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+			ri := dc.Resource(gvr).Namespace("default")
+
+			var err error
+			if tc.object {
+				err = client.DeleteObject(context.TODO(), testConfigMap("foo", "default"), metav1.DeleteOptions{})
+			} else {
+				err = client.DeleteResourcesWithOptions(context.TODO(), []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{testConfigMap("foo", "default")}, DeleteOptions{})
+			}
+
+			assert.NoError(t, err, "Delete should have succeeded.")
+			assert.Equal(t, tc.attempts, attempts, "Unexpected number of attempts.")
+
+			_, err = ri.Get(context.TODO(), "foo", metav1.GetOptions{})
+			assert.True(t, apierrors.IsNotFound(err), "ConfigMap was not deleted.")
+		})
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"sort"
+)
+
+// kindOrder  The order in which kinds should be applied, so that things exist before whatever depends on them.  Namespaces and CRDs come early, workloads late.  Kinds not listed here (custom resources, mostly) go after all of these.
+var kindOrder = []string{
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"SecretList",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleList",
+	"ClusterRoleBinding",
+	"ClusterRoleBindingList",
+	"Role",
+	"RoleList",
+	"RoleBinding",
+	"RoleBindingList",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+}
+
+// kindPriority  Returns the position of the kind in kindOrder.  Unknown kinds sort last.
+func kindPriority(kind string) (priority int) {
+	for i, k := range kindOrder {
+		if k == kind {
+			return i
+		}
+	}
+
+	return len(kindOrder)
+}
+
+// SortObjectsByKind  Reorders a list of Unstructured interfaces and 'objects' so that foundational kinds (Namespaces, CRDs, RBAC, etc) are applied before the things that depend on them, similar to what kubectl and helm do.  The sort is stable, so objects of the same kind stay in file order.  The returned slices are new, and stay index-aligned.  The inputs are not modified.
+func SortObjectsByKind(interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured) (sortedInterfaces []dynamic.ResourceInterface, sortedObjects []*unstructured.Unstructured) {
+	indices := make([]int, len(objects))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	sort.SliceStable(indices, func(a, b int) bool {
+		return kindPriority(objects[indices[a]].GetKind()) < kindPriority(objects[indices[b]].GetKind())
+	})
+
+	sortedInterfaces = make([]dynamic.ResourceInterface, 0, len(interfaces))
+	sortedObjects = make([]*unstructured.Unstructured, 0, len(objects))
+
+	for _, i := range indices {
+		sortedInterfaces = append(sortedInterfaces, interfaces[i])
+		sortedObjects = append(sortedObjects, objects[i])
+	}
+
+	return sortedInterfaces, sortedObjects
+}
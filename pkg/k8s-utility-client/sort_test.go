@@ -0,0 +1,75 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+func TestSortObjectsByKind(t *testing.T) {
+	testCases := []struct {
+		name     string
+		kinds    []string
+		expected []string
+	}{
+		{
+			"crd and custom resource",
+			[]string{"Widget", "CustomResourceDefinition"},
+			[]string{"CustomResourceDefinition", "Widget"},
+		},
+		{
+			"namespace last in file",
+			[]string{"Deployment", "Service", "ConfigMap", "Namespace"},
+			[]string{"Namespace", "ConfigMap", "Service", "Deployment"},
+		},
+		{
+			"stable within a kind",
+			[]string{"ConfigMap", "Widget", "ConfigMap", "Gadget"},
+			[]string{"ConfigMap", "ConfigMap", "Widget", "Gadget"},
+		},
+	}
+
+	dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			interfaces := make([]dynamic.ResourceInterface, 0)
+			objects := make([]*unstructured.Unstructured, 0)
+			expectedInterfaces := make(map[*unstructured.Unstructured]dynamic.ResourceInterface)
+
+			for _, kind := range tc.kinds {
+				obj := &unstructured.Unstructured{}
+				obj.SetKind(kind)
+				ri := dc.Resource(schema.GroupVersionResource{Resource: kind})
+
+				objects = append(objects, obj)
+				interfaces = append(interfaces, ri)
+				expectedInterfaces[obj] = ri
+			}
+
+			sortedInterfaces, sortedObjects := SortObjectsByKind(interfaces, objects)
+
+			actual := make([]string, 0)
+			for i, obj := range sortedObjects {
+				actual = append(actual, obj.GetKind())
+				assert.Equal(t, expectedInterfaces[obj], sortedInterfaces[i], "Interfaces and objects are no longer aligned.")
+			}
+
+			assert.Equal(t, tc.expected, actual, "Objects were not sorted by kind.")
+			assert.Equal(t, tc.kinds[0], objects[0].GetKind(), "Input slice was modified.")
+		})
+	}
+}
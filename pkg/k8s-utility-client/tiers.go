@@ -0,0 +1,147 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"time"
+)
+
+// NAMESPACE_ACTIVE_TIMEOUT  How long a tiered apply waits for a Namespace to become Active before applying what goes in it.
+const NAMESPACE_ACTIVE_TIMEOUT = time.Minute
+
+// namespaceGVR  The resource for Namespaces, accessed through the dynamic client like everything else.
+var namespaceGVR = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+
+// WaitForNamespaceActive  Polls the named Namespace until its phase is Active, or the timeout fires.  A Namespace that's still Terminating from an earlier delete won't accept new objects.
+func (k *K8sClients) WaitForNamespaceActive(ctx context.Context, name string, timeout time.Duration) (err error) {
+	status := "not found"
+
+	err = wait.PollImmediateWithContext(ctx, POLL_INTERVAL, timeout, func(ctx context.Context) (done bool, err error) {
+		ns, err := k.DynamicClient.Resource(namespaceGVR).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				status = "not found"
+				return false, nil
+			}
+
+			err = errors.Wrapf(err, "failed getting namespace %s", name)
+			return false, err
+		}
+
+		phase, _, _ := unstructured.NestedString(ns.Object, "status", "phase")
+		status = fmt.Sprintf("phase %q", phase)
+
+		return phase == "Active", nil
+	})
+
+	if err == wait.ErrWaitTimeout {
+		if ctx.Err() != nil {
+			err = errors.Wrapf(ctx.Err(), "stopped waiting for namespace %s to become active: %s", name, status)
+			return err
+		}
+
+		err = errors.New(fmt.Sprintf("timed out after %s waiting for namespace %s to become active: %s", timeout, name, status))
+		return err
+	}
+
+	return err
+}
+
+// applyTier  Which tier of a tiered apply an object belongs in.  Namespaces first, then CRDs, then everything else.
+func applyTier(obj *unstructured.Unstructured) (tier int) {
+	switch {
+	case obj.GetKind() == "Namespace" && obj.GroupVersionKind().Group == "":
+		return 0
+	case isCRD(obj):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// applyTiered  Does the work for ApplyOptions.Tiered.  Each tier is applied with the rest of the options, then its Namespaces are waited on until Active, and its CRDs until established, before the next tier starts.
+func (k *K8sClients) applyTiered(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, opts ApplyOptions) (result ApplyResult, err error) {
+	tierInterfaces := make([][]dynamic.ResourceInterface, 3)
+	tierObjects := make([][]*unstructured.Unstructured, 3)
+
+	for i, obj := range objects {
+		tier := applyTier(obj)
+		tierInterfaces[tier] = append(tierInterfaces[tier], interfaces[i])
+		tierObjects[tier] = append(tierObjects[tier], obj)
+	}
+
+	// only used with ContinueOnError
+	errs := make([]error, 0)
+	offset := 0
+
+	for tier := range tierObjects {
+		if len(tierObjects[tier]) == 0 {
+			continue
+		}
+
+		// report progress against the whole list, not just this tier
+		tierOpts := opts
+		tierOpts.Tiered = false
+		tierOffset := offset
+		tierOpts.OnProgress = func(index int, total int, obj *unstructured.Unstructured, action string) {
+			opts.progress(tierOffset+index, len(objects), obj, action)
+		}
+
+		offset += len(tierObjects[tier])
+
+		tierResult, err := k.ApplyResourcesWithResult(ctx, tierInterfaces[tier], tierObjects[tier], tierOpts)
+		result.Objects = append(result.Objects, tierResult.Objects...)
+		if err != nil {
+			if !opts.ContinueOnError {
+				return result, err
+			}
+
+			errs = append(errs, err)
+		}
+
+		// a dry run never creates anything, so there's nothing to wait for
+		if opts.DryRun {
+			continue
+		}
+
+		for _, obj := range tierObjects[tier] {
+			switch applyTier(obj) {
+			case 0:
+				err = k.WaitForNamespaceActive(ctx, obj.GetName(), NAMESPACE_ACTIVE_TIMEOUT)
+			case 1:
+				err = k.WaitForCRDEstablished(ctx, obj.GetName(), CRD_ESTABLISHED_TIMEOUT)
+			default:
+				err = nil
+			}
+
+			if err != nil {
+				if !opts.ContinueOnError {
+					return result, err
+				}
+
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	err = utilerrors.NewAggregate(errs)
+
+	return result, err
+}
@@ -0,0 +1,157 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"testing"
+	"time"
+)
+
+func TestApplyTier(t *testing.T) {
+	testCases := []struct {
+		name string
+		obj  *unstructured.Unstructured
+		tier int
+	}{
+		{
+			"namespace",
+			&unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Namespace"}},
+			0,
+		},
+		{
+			"crd",
+			testCRD("widgets.utility-client.example.com", ""),
+			1,
+		},
+		{
+			"configmap",
+			testConfigMap("foo", "default"),
+			2,
+		},
+		{
+			"namespace kind in another group",
+			&unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "utility-client.example.com/v1", "kind": "Namespace"}},
+			2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.tier, applyTier(tc.obj), "Unexpected tier.")
+		})
+	}
+}
+
+func TestApplyResourcesTieredFake(t *testing.T) {
+	dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	// the fake has no controllers, so activate namespaces and establish CRDs ourselves
+	dc.PrependReactor("create", "namespaces", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+		ns := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		_ = unstructured.SetNestedField(ns.Object, "Active", "status", "phase")
+
+		return false, nil, nil
+	})
+
+	dc.PrependReactor("create", "customresourcedefinitions", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+		crd := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		_ = unstructured.SetNestedSlice(crd.Object, []interface{}{
+			map[string]interface{}{"type": "Established", "status": "True"},
+		}, "status", "conditions")
+
+		return false, nil, nil
+	})
+
+	// record creates, and the gets of the readiness waits, in the order they happen
+	calls := make([]string, 0)
+	dc.PrependReactor("*", "*", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
+		switch a := action.(type) {
+		case k8stesting.CreateAction:
+			obj := a.GetObject().(*unstructured.Unstructured)
+			calls = append(calls, fmt.Sprintf("create %s/%s", a.GetResource().Resource, obj.GetName()))
+		case k8stesting.GetAction:
+			if a.GetResource().Resource == "namespaces" || a.GetResource().Resource == "customresourcedefinitions" {
+				calls = append(calls, fmt.Sprintf("get %s/%s", a.GetResource().Resource, a.GetName()))
+			}
+		}
+
+		return false, nil, nil
+	})
+
+	client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+	interfaces, objects, err := client.ResourcesAndObjectsFromFile("test_fixtures/tiered.yaml")
+	if err != nil {
+		t.Fatalf("failed loading tiered manifest: %s", err)
+	}
+
+	progress := make([]int, 0)
+	opts := ApplyOptions{
+		Tiered: true,
+		OnProgress: func(index int, total int, obj *unstructured.Unstructured, action string) {
+			assert.Equal(t, len(objects), total, "Progress should count every object, not just the tier.")
+			if action == APPLY_STARTING {
+				progress = append(progress, index)
+			}
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
+	defer cancel()
+
+	result, err := client.ApplyResourcesWithResult(ctx, interfaces, objects, opts)
+	if err != nil {
+		t.Fatalf("failed tiered apply: %s", err)
+	}
+
+	assert.Equal(t, len(objects), len(result.Objects), "Every object should have a result.")
+	assert.Equal(t, []int{0, 1, 2, 3}, progress, "Progress should be reported in order across tiers.")
+
+	// creates happen before the apply's own existence checks, so find each step's first occurrence
+	position := func(call string) int {
+		for i, c := range calls {
+			if c == call {
+				return i
+			}
+		}
+
+		t.Fatalf("%q never happened: %v", call, calls)
+		return -1
+	}
+
+	nsCreated := position("create namespaces/utility-client-tiers")
+	crdCreated := position("create customresourcedefinitions/widgets.utility-client.example.com")
+	widgetCreated := position("create widgets/utility-client-widget")
+	cmCreated := position("create configmaps/utility-client-tiers")
+
+	// each tier is waited on after it's created, and before the next tier goes in
+	waitedAfter := func(call string, after int) int {
+		for i := after + 1; i < len(calls); i++ {
+			if calls[i] == call {
+				return i
+			}
+		}
+
+		return -1
+	}
+
+	nsWaited := waitedAfter("get namespaces/utility-client-tiers", nsCreated)
+	crdWaited := waitedAfter("get customresourcedefinitions/widgets.utility-client.example.com", crdCreated)
+
+	assert.True(t, nsWaited > nsCreated && nsWaited < crdCreated, "Namespace should be waited on before the CRD tier: %v", calls)
+	assert.True(t, crdWaited > crdCreated && crdWaited < widgetCreated && crdWaited < cmCreated, "CRD should be waited on before everything else: %v", calls)
+}
@@ -0,0 +1,156 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/kube-openapi/pkg/util/proto"
+	"k8s.io/kube-openapi/pkg/util/proto/validation"
+	"strings"
+)
+
+// GVK_EXTENSION  The OpenAPI vendor extension naming the GroupVersionKinds a schema describes.
+const GVK_EXTENSION = "x-kubernetes-group-version-kind"
+
+// ValidateObjects  Checks, without touching the cluster, that every object has an apiVersion, a kind, and a name.  Catches the usual culprits, an empty document or a stray `---`, before they turn into a confusing error from the API server.  Returns an aggregate error listing every offender by its index, or nil if they're all fine.
+func ValidateObjects(objects []*unstructured.Unstructured) (err error) {
+	errs := make([]error, 0)
+
+	for i, obj := range objects {
+		if obj == nil {
+			errs = append(errs, errors.New(fmt.Sprintf("object %d: empty", i)))
+			continue
+		}
+
+		missing := make([]string, 0)
+
+		if obj.GetAPIVersion() == "" {
+			missing = append(missing, "apiVersion")
+		}
+
+		if obj.GetKind() == "" {
+			missing = append(missing, "kind")
+		}
+
+		if obj.GetName() == "" && obj.GetGenerateName() == "" {
+			missing = append(missing, "metadata.name")
+		}
+
+		if len(missing) > 0 {
+			errs = append(errs, errors.New(fmt.Sprintf("object %d: missing %s", i, strings.Join(missing, ", "))))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// ValidateAgainstSchema  Validates objects against the OpenAPI schema published by the cluster, like `kubectl apply --validate=true`.  Catches unknown fields, which the API server would otherwise silently drop, and fields of the wrong type.  Kinds the schema doesn't describe, such as custom resources without a structural schema, are skipped.  Returns an aggregate error listing every problem found, or nil.
+func (k *K8sClients) ValidateAgainstSchema(ctx context.Context, objects []*unstructured.Unstructured) (err error) {
+	// fetching the schema can't be cancelled, so at least don't start if we're already done
+	if ctx.Err() != nil {
+		err = errors.Wrapf(ctx.Err(), "stopped before fetching openapi schema")
+		return err
+	}
+
+	d := k.Discovery()
+	if d == nil {
+		err = errors.New("no k8s clientset from which to fetch the openapi schema")
+		return err
+	}
+
+	doc, err := d.OpenAPISchema()
+	if err != nil {
+		err = errors.Wrapf(err, "failed fetching openapi schema")
+		return err
+	}
+
+	models, err := proto.NewOpenAPIData(doc)
+	if err != nil {
+		err = errors.Wrapf(err, "failed parsing openapi schema")
+		return err
+	}
+
+	err = validateAgainstModels(models, objects)
+
+	return err
+}
+
+// validateAgainstModels  Validates each object against the model for its kind.
+func validateAgainstModels(models proto.Models, objects []*unstructured.Unstructured) (err error) {
+	schemas := schemasByKind(models)
+	errs := make([]error, 0)
+
+	for i, obj := range objects {
+		s, ok := schemas[obj.GroupVersionKind()]
+		if !ok {
+			continue
+		}
+
+		for _, e := range validation.ValidateModel(obj.Object, s, obj.GetKind()) {
+			errs = append(errs, errors.Wrapf(e, "object %d (%s %s)", i, obj.GetKind(), obj.GetName()))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// schemasByKind  Indexes the models by the GroupVersionKinds they describe.
+func schemasByKind(models proto.Models) (schemas map[schema.GroupVersionKind]proto.Schema) {
+	schemas = make(map[schema.GroupVersionKind]proto.Schema)
+
+	for _, name := range models.ListModels() {
+		model := models.LookupModel(name)
+		if model == nil {
+			continue
+		}
+
+		gvks, ok := model.GetExtensions()[GVK_EXTENSION].([]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, g := range gvks {
+			gvk, ok := extensionGVK(g)
+			if ok {
+				schemas[gvk] = model
+			}
+		}
+	}
+
+	return schemas
+}
+
+// extensionGVK  Reads a GroupVersionKind out of an x-kubernetes-group-version-kind entry.  Depending on how the schema was decoded, the entry's keys may or may not be strings.
+func extensionGVK(entry interface{}) (gvk schema.GroupVersionKind, ok bool) {
+	fields := make(map[string]string)
+
+	switch e := entry.(type) {
+	case map[string]interface{}:
+		for key, value := range e {
+			fields[key], _ = value.(string)
+		}
+	case map[interface{}]interface{}:
+		for key, value := range e {
+			k, _ := key.(string)
+			fields[k], _ = value.(string)
+		}
+	default:
+		return gvk, false
+	}
+
+	gvk = schema.GroupVersionKind{Group: fields["group"], Version: fields["version"], Kind: fields["kind"]}
+
+	return gvk, gvk.Kind != ""
+}
@@ -0,0 +1,182 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	openapi_v2 "github.com/google/gnostic/openapiv2"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/kube-openapi/pkg/util/proto"
+	"os"
+	"testing"
+)
+
+func TestValidateObjects(t *testing.T) {
+	noKind := testConfigMap("no-kind", "default")
+	noKind.SetKind("")
+
+	noName := testConfigMap("", "default")
+
+	empty := &unstructured.Unstructured{Object: map[string]interface{}{}}
+
+	testCases := []struct {
+		name     string
+		objects  []*unstructured.Unstructured
+		offences []string
+	}{
+		{
+			"all valid",
+			[]*unstructured.Unstructured{testConfigMap("one", "default"), testConfigMap("two", "default")},
+			[]string{},
+		},
+		{
+			"missing kind",
+			[]*unstructured.Unstructured{testConfigMap("one", "default"), noKind},
+			[]string{"object 1: missing kind"},
+		},
+		{
+			"mixed",
+			[]*unstructured.Unstructured{empty, testConfigMap("one", "default"), noName, nil},
+			[]string{
+				"object 0: missing apiVersion, kind, metadata.name",
+				"object 2: missing metadata.name",
+				"object 3: empty",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateObjects(tc.objects)
+			if len(tc.offences) == 0 {
+				assert.NoError(t, err, "Valid objects failed validation.")
+				return
+			}
+
+			agg, ok := err.(utilerrors.Aggregate)
+			if !assert.True(t, ok, "Expected an aggregate error.  Got: %v", err) {
+				return
+			}
+
+			actual := make([]string, 0)
+			for _, e := range agg.Errors() {
+				actual = append(actual, e.Error())
+			}
+
+			assert.Equal(t, tc.offences, actual, "Validation errors do not match expectations.")
+		})
+	}
+}
+
+func TestValidateAgainstModels(t *testing.T) {
+	b, err := os.ReadFile("test_fixtures/openapi.json")
+	if err != nil {
+		t.Fatalf("failed reading openapi fixture: %s", err)
+	}
+
+	doc, err := openapi_v2.ParseDocument(b)
+	if err != nil {
+		t.Fatalf("failed parsing openapi fixture: %s", err)
+	}
+
+	models, err := proto.NewOpenAPIData(doc)
+	if err != nil {
+		t.Fatalf("failed building models: %s", err)
+	}
+
+	bogusField := testConfigMap("bogus-field", "default")
+	bogusField.Object["dta"] = map[string]interface{}{"foo": "bar"}
+
+	wrongType := testConfigMap("wrong-type", "default")
+	wrongType.Object["immutable"] = "yes"
+
+	unknownKind := testDeployment("unknown-kind", 1, 1)
+	unknownKind.Object["bogus"] = true
+
+	testCases := []struct {
+		name     string
+		objects  []*unstructured.Unstructured
+		problems []string
+	}{
+		{
+			"valid",
+			[]*unstructured.Unstructured{testConfigMap("valid", "default")},
+			[]string{},
+		},
+		{
+			"bogus field",
+			[]*unstructured.Unstructured{testConfigMap("valid", "default"), bogusField},
+			[]string{"object 1 (ConfigMap bogus-field)", `unknown field "dta"`},
+		},
+		{
+			"wrong type",
+			[]*unstructured.Unstructured{wrongType},
+			[]string{"object 0 (ConfigMap wrong-type)", `got "string", expected "boolean"`},
+		},
+		{
+			"kind not in schema",
+			[]*unstructured.Unstructured{unknownKind},
+			[]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateAgainstModels(models, tc.objects)
+			if len(tc.problems) == 0 {
+				assert.NoError(t, err, "Unexpected validation error.")
+				return
+			}
+
+			if assert.Error(t, err, "Expected a validation error.") {
+				for _, problem := range tc.problems {
+					assert.Contains(t, err.Error(), problem, "Validation error does not describe the problem.")
+				}
+			}
+		})
+	}
+}
+
+func TestValidateAgainstSchema(t *testing.T) {
+	client, err := NewK8sClients()
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	_, objects, err := client.ResourcesAndObjectsFromFile("test_fixtures/resources.yaml")
+	if err != nil {
+		t.Fatalf("failed to load yaml file: %s", err)
+	}
+
+	err = client.ValidateAgainstSchema(context.TODO(), objects)
+	assert.NoError(t, err, "Valid manifest failed schema validation.")
+
+	// a typo in a field name that the API server would otherwise silently drop
+	err = unstructured.SetNestedField(objects[0].Object, int64(2), "spec", "replica")
+	if err != nil {
+		t.Fatalf("failed adding bogus field: %s", err)
+	}
+
+	err = client.ValidateAgainstSchema(context.TODO(), objects)
+	if assert.Error(t, err, "Expected a schema validation error.") {
+		assert.Contains(t, err.Error(), `unknown field "replica"`, "Validation error does not name the bogus field.")
+	}
+}
+
+func TestValidateAgainstSchemaWithoutClientSet(t *testing.T) {
+	client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())}
+
+	err := client.ValidateAgainstSchema(context.TODO(), []*unstructured.Unstructured{testConfigMap("schemaless", "default")})
+	assert.Error(t, err, "Expected an error validating without a clientset.")
+}
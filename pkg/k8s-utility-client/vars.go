@@ -0,0 +1,141 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ResourcesAndObjectsFromBytesWithVars  Converts k8s yaml into Unstructured interfaces like ResourcesAndObjectsFromBytes, but first expands $VAR and ${VAR} placeholders, like envsubst.  Values are looked up in vars, falling back to the environment.  If strict is true, any placeholder that can't be resolved is an error.  Otherwise it's left in the yaml exactly as written.  Dollar signs that aren't part of a placeholder, such as $$ or $1, are left alone.
+//
+// Be careful with manifests that legitimately contain dollar signs, such as shell scripts in ConfigMaps.  They'll be expanded too.
+func (k *K8sClients) ResourcesAndObjectsFromBytesWithVars(yamlBytes []byte, vars map[string]string, strict bool) (interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, err error) {
+	expanded, err := expandVars(yamlBytes, vars, strict)
+	if err != nil {
+		return interfaces, objects, err
+	}
+
+	return k.ResourcesAndObjectsFromReader(bytes.NewReader(expanded))
+}
+
+// expandVars  Does the substitution for ResourcesAndObjectsFromBytesWithVars.  Only $NAME and ${NAME}, where NAME is a letter or underscore followed by letters, digits, and underscores, are placeholders.  Anything else with a dollar sign, such as $$, $1, $@, or a malformed ${, is passed through as is, as is any placeholder that can't be resolved, exactly as written.
+func expandVars(yamlBytes []byte, vars map[string]string, strict bool) (expanded []byte, err error) {
+	unresolved := make(map[string]bool)
+	input := string(yamlBytes)
+
+	var result strings.Builder
+
+	for i := 0; i < len(input); {
+		if input[i] != '$' {
+			result.WriteByte(input[i])
+			i++
+			continue
+		}
+
+		name, length := placeholderAt(input[i:])
+		if length == 0 {
+			result.WriteByte('$')
+			i++
+			continue
+		}
+
+		if value, ok := lookupVar(name, vars); ok {
+			result.WriteString(value)
+		} else {
+			unresolved[name] = true
+			result.WriteString(input[i : i+length])
+		}
+
+		i += length
+	}
+
+	if strict && len(unresolved) > 0 {
+		names := make([]string, 0)
+		for name := range unresolved {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		err = errors.New(fmt.Sprintf("unresolved variables in manifest: %s", strings.Join(names, ", ")))
+		return expanded, err
+	}
+
+	expanded = []byte(result.String())
+
+	return expanded, err
+}
+
+// placeholderAt  Parses the $NAME or ${NAME} placeholder at the start of s, returning the name, and the length of the placeholder as written.  The length is zero if s doesn't start with a well formed placeholder.
+func placeholderAt(s string) (name string, length int) {
+	if len(s) < 2 || s[0] != '$' {
+		return name, length
+	}
+
+	if s[1] == '{' {
+		end := strings.IndexByte(s, '}')
+		if end < 0 || !isVarName(s[2:end]) {
+			return name, length
+		}
+
+		return s[2:end], end + 1
+	}
+
+	end := 1
+	for end < len(s) && isVarChar(s[end], end == 1) {
+		end++
+	}
+
+	if end == 1 {
+		return name, length
+	}
+
+	return s[1:end], end
+}
+
+// isVarName  Whether s is a valid variable name: a letter or underscore, followed by letters, digits, and underscores.
+func isVarName(s string) (valid bool) {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !isVarChar(s[i], i == 0) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isVarChar  Whether c can appear in a variable name.  Digits can't come first.
+func isVarChar(c byte, first bool) (valid bool) {
+	if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+		return true
+	}
+
+	return !first && c >= '0' && c <= '9'
+}
+
+// lookupVar  Finds a variable's value in vars, falling back to the environment.
+func lookupVar(name string, vars map[string]string) (value string, ok bool) {
+	if value, ok = vars[name]; ok {
+		return value, ok
+	}
+
+	return os.LookupEnv(name)
+}
@@ -0,0 +1,205 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"testing"
+)
+
+func TestResourcesAndObjectsFromBytesWithVars(t *testing.T) {
+	manifest := `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: $UTILITY_CLIENT_NAME
+  namespace: default
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          image: nginx:${UTILITY_CLIENT_TAG}
+`
+
+	testCases := []struct {
+		name     string
+		vars     map[string]string
+		env      map[string]string
+		strict   bool
+		errors   bool
+		expected []string
+	}{
+		{
+			"vars",
+			map[string]string{"UTILITY_CLIENT_NAME": "web", "UTILITY_CLIENT_TAG": "1.23"},
+			map[string]string{},
+			true,
+			false,
+			[]string{"web", "nginx:1.23"},
+		},
+		{
+			"vars take precedence over the environment",
+			map[string]string{"UTILITY_CLIENT_NAME": "web", "UTILITY_CLIENT_TAG": "1.23"},
+			map[string]string{"UTILITY_CLIENT_TAG": "latest"},
+			true,
+			false,
+			[]string{"web", "nginx:1.23"},
+		},
+		{
+			"falls back to the environment",
+			map[string]string{"UTILITY_CLIENT_NAME": "web"},
+			map[string]string{"UTILITY_CLIENT_TAG": "1.24"},
+			true,
+			false,
+			[]string{"web", "nginx:1.24"},
+		},
+		{
+			"unresolved left intact",
+			map[string]string{"UTILITY_CLIENT_NAME": "web"},
+			map[string]string{},
+			false,
+			false,
+			[]string{"web", "nginx:${UTILITY_CLIENT_TAG}"},
+		},
+		{
+			"unresolved is an error when strict",
+			map[string]string{"UTILITY_CLIENT_NAME": "web"},
+			map[string]string{},
+			true,
+			true,
+			[]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for name, value := range tc.env {
+				t.Setenv(name, value)
+			}
+
+			client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+			_, objects, err := client.ResourcesAndObjectsFromBytesWithVars([]byte(manifest), tc.vars, tc.strict)
+			if tc.errors {
+				if assert.Error(t, err, "Expected an error for an unresolved variable.") {
+					assert.Contains(t, err.Error(), "UTILITY_CLIENT_TAG", "Error does not name the unresolved variable.")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed to load manifest: %s", err)
+			}
+
+			if assert.Equal(t, 1, len(objects), "Expected exactly one object.") {
+				containers, _, _ := unstructured.NestedSlice(objects[0].Object, "spec", "template", "spec", "containers")
+				image, _, _ := unstructured.NestedString(containers[0].(map[string]interface{}), "image")
+
+				assert.Equal(t, tc.expected, []string{objects[0].GetName(), image}, "Substituted values do not match expectations.")
+			}
+		})
+	}
+}
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{"UTILITY_CLIENT_NAME": "web"}
+
+	testCases := []struct {
+		name     string
+		input    string
+		strict   bool
+		expected string
+		errors   bool
+	}{
+		{
+			"both forms resolved",
+			"a-$UTILITY_CLIENT_NAME-${UTILITY_CLIENT_NAME}b",
+			true,
+			"a-web-webb",
+			false,
+		},
+		{
+			"bare unresolved left as written",
+			"image: nginx:$UTILITY_CLIENT_MISSING",
+			false,
+			"image: nginx:$UTILITY_CLIENT_MISSING",
+			false,
+		},
+		{
+			"braced unresolved left as written",
+			"image: nginx:${UTILITY_CLIENT_MISSING}",
+			false,
+			"image: nginx:${UTILITY_CLIENT_MISSING}",
+			false,
+		},
+		{
+			"double dollar",
+			"echo $$ $UTILITY_CLIENT_NAME",
+			true,
+			"echo $$ web",
+			false,
+		},
+		{
+			"shell specials",
+			`echo "$1" "$@" "$?" "$*" $`,
+			true,
+			`echo "$1" "$@" "$?" "$*" $`,
+			false,
+		},
+		{
+			"unclosed brace",
+			"echo ${UTILITY_CLIENT_NAME",
+			true,
+			"echo ${UTILITY_CLIENT_NAME",
+			false,
+		},
+		{
+			"empty braces",
+			"echo ${} ${UTILITY_CLIENT_NAME}",
+			true,
+			"echo ${} web",
+			false,
+		},
+		{
+			"not a name",
+			"echo ${foo-bar} ${1}",
+			true,
+			"echo ${foo-bar} ${1}",
+			false,
+		},
+		{
+			"unresolved is an error when strict",
+			"$UTILITY_CLIENT_MISSING",
+			true,
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expanded, err := expandVars([]byte(tc.input), vars, tc.strict)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error for an unresolved variable.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed expanding vars: %s", err)
+			}
+
+			assert.Equal(t, tc.expected, string(expanded), "Expanded text does not match expectations.")
+		})
+	}
+}
@@ -0,0 +1,295 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"strings"
+	"time"
+)
+
+// POLL_INTERVAL  How often the Wait* functions check on the cluster.
+const POLL_INTERVAL = 2 * time.Second
+
+// WaitForReady  Polls a list of Unstructured interfaces and 'objects' until they're all ready, or the timeout fires.  Deployments, StatefulSets, and DaemonSets are ready when all their desired replicas are updated and ready, Jobs when they've completed, and Pods when they're Running and Ready.  Anything else is ready as soon as it exists.  On timeout the error lists whatever never became ready.
+func (k *K8sClients) WaitForReady(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, timeout time.Duration) (err error) {
+	notReady := make([]string, 0)
+
+	err = wait.PollImmediateWithContext(ctx, POLL_INTERVAL, timeout, func(ctx context.Context) (done bool, err error) {
+		notReady = make([]string, 0)
+
+		for i, ri := range interfaces {
+			obj := objects[i]
+
+			live, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					notReady = append(notReady, fmt.Sprintf("%s %s (not found)", obj.GetKind(), obj.GetName()))
+					continue
+				}
+
+				err = errors.Wrapf(err, "failed getting %s kind %s", obj.GetName(), obj.GetKind())
+				return false, err
+			}
+
+			ready, status, err := resourceReady(live)
+			if err != nil {
+				return false, err
+			}
+
+			if !ready {
+				notReady = append(notReady, fmt.Sprintf("%s %s (%s)", obj.GetKind(), obj.GetName(), status))
+			}
+		}
+
+		return len(notReady) == 0, nil
+	})
+
+	if err == wait.ErrWaitTimeout {
+		if ctx.Err() != nil {
+			err = errors.Wrapf(ctx.Err(), "stopped waiting for resources to become ready: %s", strings.Join(notReady, ", "))
+			return err
+		}
+
+		err = errors.New(fmt.Sprintf("timed out after %s waiting for resources to become ready: %s", timeout, strings.Join(notReady, ", ")))
+		return err
+	}
+
+	return err
+}
+
+// WaitForDeletion  Polls a list of Unstructured interfaces and 'objects' until they're all gone, or the timeout fires.  Deletes return as soon as the API server accepts them, but finalizers and foreground deletion can keep an object around for a while afterwards.  On timeout the error lists whatever is still present.
+func (k *K8sClients) WaitForDeletion(ctx context.Context, interfaces []dynamic.ResourceInterface, objects []*unstructured.Unstructured, timeout time.Duration) (err error) {
+	present := make([]string, 0)
+
+	err = wait.PollImmediateWithContext(ctx, POLL_INTERVAL, timeout, func(ctx context.Context) (done bool, err error) {
+		present = make([]string, 0)
+
+		for i, ri := range interfaces {
+			obj := objects[i]
+
+			_, err := ri.Get(ctx, obj.GetName(), metav1.GetOptions{})
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+
+				err = errors.Wrapf(err, "failed getting %s kind %s", obj.GetName(), obj.GetKind())
+				return false, err
+			}
+
+			present = append(present, fmt.Sprintf("%s %s", obj.GetKind(), obj.GetName()))
+		}
+
+		return len(present) == 0, nil
+	})
+
+	if err == wait.ErrWaitTimeout {
+		if ctx.Err() != nil {
+			err = errors.Wrapf(ctx.Err(), "stopped waiting for resources to be deleted: %s", strings.Join(present, ", "))
+			return err
+		}
+
+		err = errors.New(fmt.Sprintf("timed out after %s waiting for resources to be deleted: %s", timeout, strings.Join(present, ", ")))
+		return err
+	}
+
+	return err
+}
+
+// WaitForCondition  Polls a resource until the condition of the given type in its status.conditions has the given status, e.g. "Ready" and "True" for a cert-manager Certificate, or "Available" and "True" for a Deployment, or the timeout fires.  Works on any kind, built-in or custom, that reports conditions the usual way.  A missing resource or condition is waited on, not treated as an error.
+func (k *K8sClients) WaitForCondition(ctx context.Context, gvk schema.GroupVersionKind, namespace string, name string, conditionType string, status string, timeout time.Duration) (err error) {
+	ri, err := k.resourceInterface(gvk, namespace)
+	if err != nil {
+		return err
+	}
+
+	current := "not found"
+
+	err = wait.PollImmediateWithContext(ctx, POLL_INTERVAL, timeout, func(ctx context.Context) (done bool, err error) {
+		live, err := ri.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				current = "not found"
+				return false, nil
+			}
+
+			err = errors.Wrapf(err, "failed getting %s kind %s", name, gvk.Kind)
+			return false, err
+		}
+
+		conditionStatus, found := objectCondition(live, conditionType)
+		if !found {
+			current = fmt.Sprintf("no %s condition", conditionType)
+			return false, nil
+		}
+
+		current = fmt.Sprintf("%s is %s", conditionType, conditionStatus)
+
+		return conditionStatus == status, nil
+	})
+
+	if err == wait.ErrWaitTimeout {
+		if ctx.Err() != nil {
+			err = errors.Wrapf(ctx.Err(), "stopped waiting for %s kind %s to have %s=%s: %s", name, gvk.Kind, conditionType, status, current)
+			return err
+		}
+
+		err = errors.New(fmt.Sprintf("timed out after %s waiting for %s kind %s to have %s=%s: %s", timeout, name, gvk.Kind, conditionType, status, current))
+		return err
+	}
+
+	return err
+}
+
+// objectCondition  Returns the status of the condition of the given type in an object's status.conditions, and whether there is one.
+func objectCondition(obj *unstructured.Unstructured, conditionType string) (status string, found bool) {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != conditionType {
+			continue
+		}
+
+		status, _ = condition["status"].(string)
+
+		return status, true
+	}
+
+	return status, false
+}
+
+// resourceReady  Reports whether a live object is ready, along with a short description of its state if it isn't.
+func resourceReady(obj *unstructured.Unstructured) (ready bool, status string, err error) {
+	switch obj.GetKind() {
+	case "Deployment":
+		var deployment appsv1.Deployment
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &deployment)
+		if err != nil {
+			err = errors.Wrapf(err, "failed converting %s to a Deployment", obj.GetName())
+			return ready, status, err
+		}
+
+		desired := int32(1)
+		if deployment.Spec.Replicas != nil {
+			desired = *deployment.Spec.Replicas
+		}
+
+		if deployment.Status.ObservedGeneration < deployment.Generation {
+			return false, "spec update not yet observed", err
+		}
+
+		ready = deployment.Status.UpdatedReplicas >= desired && deployment.Status.AvailableReplicas >= desired
+		status = fmt.Sprintf("%d of %d replicas updated, %d available", deployment.Status.UpdatedReplicas, desired, deployment.Status.AvailableReplicas)
+
+	case "StatefulSet":
+		var statefulSet appsv1.StatefulSet
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &statefulSet)
+		if err != nil {
+			err = errors.Wrapf(err, "failed converting %s to a StatefulSet", obj.GetName())
+			return ready, status, err
+		}
+
+		desired := int32(1)
+		if statefulSet.Spec.Replicas != nil {
+			desired = *statefulSet.Spec.Replicas
+		}
+
+		if statefulSet.Status.ObservedGeneration < statefulSet.Generation {
+			return false, "spec update not yet observed", err
+		}
+
+		ready = statefulSet.Status.UpdatedReplicas >= desired && statefulSet.Status.ReadyReplicas >= desired
+		status = fmt.Sprintf("%d of %d replicas updated, %d ready", statefulSet.Status.UpdatedReplicas, desired, statefulSet.Status.ReadyReplicas)
+
+	case "DaemonSet":
+		var daemonSet appsv1.DaemonSet
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &daemonSet)
+		if err != nil {
+			err = errors.Wrapf(err, "failed converting %s to a DaemonSet", obj.GetName())
+			return ready, status, err
+		}
+
+		if daemonSet.Status.ObservedGeneration < daemonSet.Generation {
+			return false, "spec update not yet observed", err
+		}
+
+		desired := daemonSet.Status.DesiredNumberScheduled
+		ready = daemonSet.Status.UpdatedNumberScheduled >= desired && daemonSet.Status.NumberReady >= desired
+		status = fmt.Sprintf("%d of %d pods updated, %d ready", daemonSet.Status.UpdatedNumberScheduled, desired, daemonSet.Status.NumberReady)
+
+	case "Job":
+		var job batchv1.Job
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &job)
+		if err != nil {
+			err = errors.Wrapf(err, "failed converting %s to a Job", obj.GetName())
+			return ready, status, err
+		}
+
+		for _, condition := range job.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+
+			switch condition.Type {
+			case batchv1.JobComplete:
+				return true, "complete", err
+			case batchv1.JobFailed:
+				err = errors.New(fmt.Sprintf("job %s failed: %s", obj.GetName(), condition.Message))
+				return false, "failed", err
+			}
+		}
+
+		status = fmt.Sprintf("%d succeeded, %d active", job.Status.Succeeded, job.Status.Active)
+
+	case "Pod":
+		var pod corev1.Pod
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &pod)
+		if err != nil {
+			err = errors.Wrapf(err, "failed converting %s to a Pod", obj.GetName())
+			return ready, status, err
+		}
+
+		status = string(pod.Status.Phase)
+
+		if pod.Status.Phase == corev1.PodSucceeded {
+			return true, status, err
+		}
+
+		if pod.Status.Phase == corev1.PodRunning {
+			for _, condition := range pod.Status.Conditions {
+				if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+					return true, status, err
+				}
+			}
+
+			status = "running, not ready"
+		}
+
+	default:
+		// it exists, and we don't know how to tell any more than that
+		ready = true
+		status = "exists"
+	}
+
+	return ready, status, err
+}
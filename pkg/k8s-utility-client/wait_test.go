@@ -0,0 +1,349 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"testing"
+	"time"
+)
+
+// testDeployment  Creates an Unstructured Deployment with the given desired and available replicas.
+func testDeployment(name string, desired int64, available int64) (obj *unstructured.Unstructured) {
+	obj = &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":       name,
+				"namespace":  "default",
+				"generation": int64(1),
+			},
+			"spec": map[string]interface{}{
+				"replicas": desired,
+			},
+			"status": map[string]interface{}{
+				"observedGeneration": int64(1),
+				"replicas":           desired,
+				"updatedReplicas":    desired,
+				"readyReplicas":      available,
+				"availableReplicas":  available,
+			},
+		},
+	}
+
+	return obj
+}
+
+func TestResourceReady(t *testing.T) {
+	testCases := []struct {
+		name   string
+		obj    *unstructured.Unstructured
+		ready  bool
+		errors bool
+	}{
+		{
+			"deployment ready",
+			testDeployment("ready", 3, 3),
+			true,
+			false,
+		},
+		{
+			"deployment not ready",
+			testDeployment("unready", 3, 1),
+			false,
+			false,
+		},
+		{
+			"job complete",
+			&unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"name": "done"},
+				"status": map[string]interface{}{
+					"conditions": []interface{}{
+						map[string]interface{}{"type": "Complete", "status": "True"},
+					},
+				},
+			}},
+			true,
+			false,
+		},
+		{
+			"job failed",
+			&unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "batch/v1",
+				"kind":       "Job",
+				"metadata":   map[string]interface{}{"name": "failed"},
+				"status": map[string]interface{}{
+					"conditions": []interface{}{
+						map[string]interface{}{"type": "Failed", "status": "True", "message": "BackoffLimitExceeded"},
+					},
+				},
+			}},
+			false,
+			true,
+		},
+		{
+			"pod running and ready",
+			&unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Pod",
+				"metadata":   map[string]interface{}{"name": "running"},
+				"status": map[string]interface{}{
+					"phase": "Running",
+					"conditions": []interface{}{
+						map[string]interface{}{"type": "Ready", "status": "True"},
+					},
+				},
+			}},
+			true,
+			false,
+		},
+		{
+			"pod pending",
+			&unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Pod",
+				"metadata":   map[string]interface{}{"name": "pending"},
+				"status":     map[string]interface{}{"phase": "Pending"},
+			}},
+			false,
+			false,
+		},
+		{
+			"configmap",
+			testConfigMap("exists", "default"),
+			true,
+			false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ready, status, err := resourceReady(tc.obj)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error checking readiness.")
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("failed checking readiness: %s", err)
+			}
+
+			assert.Equal(t, tc.ready, ready, "Unexpected readiness.  Status: %s", status)
+		})
+	}
+}
+
+func TestWaitForReadyTimeout(t *testing.T) {
+	gvr := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testDeployment("ready", 1, 1), testDeployment("stuck", 3, 1))
+
+	client := &K8sClients{DynamicClient: dc}
+	ri := dc.Resource(gvr).Namespace("default")
+	interfaces := []dynamic.ResourceInterface{ri, ri}
+	objects := []*unstructured.Unstructured{testDeployment("ready", 1, 0), testDeployment("stuck", 3, 0)}
+
+	err := client.WaitForReady(context.TODO(), interfaces, objects, 100*time.Millisecond)
+	if assert.Error(t, err, "Expected a timeout waiting for a stuck deployment.") {
+		assert.Contains(t, err.Error(), "Deployment stuck", "Timeout error does not list the stuck resource.")
+		assert.NotContains(t, err.Error(), "Deployment ready", "Timeout error lists a ready resource.")
+	}
+}
+
+func TestWaitForDeletionTimeout(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), testConfigMap("lingering", "default"))
+
+	client := &K8sClients{DynamicClient: dc}
+	ri := dc.Resource(gvr).Namespace("default")
+	interfaces := []dynamic.ResourceInterface{ri, ri}
+	objects := []*unstructured.Unstructured{testConfigMap("gone", "default"), testConfigMap("lingering", "default")}
+
+	err := client.WaitForDeletion(context.TODO(), interfaces, objects, 100*time.Millisecond)
+	if assert.Error(t, err, "Expected a timeout waiting for a lingering configmap.") {
+		assert.Contains(t, err.Error(), "ConfigMap lingering", "Timeout error does not list the lingering resource.")
+		assert.NotContains(t, err.Error(), "ConfigMap gone", "Timeout error lists a deleted resource.")
+	}
+
+	err = client.WaitForDeletion(context.TODO(), interfaces[:1], objects[:1], 100*time.Millisecond)
+	assert.NoError(t, err, "Waiting for an already deleted resource errored.")
+}
+
+func TestWaitForConditionFake(t *testing.T) {
+	testCases := []struct {
+		name          string
+		exists        bool
+		conditions    []interface{}
+		conditionType string
+		status        string
+		errors        bool
+	}{
+		{
+			"condition met",
+			true,
+			[]interface{}{
+				map[string]interface{}{"type": "Progressing", "status": "True"},
+				map[string]interface{}{"type": "Available", "status": "True"},
+			},
+			"Available",
+			"True",
+			false,
+		},
+		{
+			"waiting for false",
+			true,
+			[]interface{}{
+				map[string]interface{}{"type": "Available", "status": "False"},
+			},
+			"Available",
+			"False",
+			false,
+		},
+		{
+			"wrong status",
+			true,
+			[]interface{}{
+				map[string]interface{}{"type": "Available", "status": "False"},
+			},
+			"Available",
+			"True",
+			true,
+		},
+		{
+			"no such condition",
+			true,
+			[]interface{}{
+				map[string]interface{}{"type": "Progressing", "status": "True"},
+			},
+			"Available",
+			"True",
+			true,
+		},
+		{
+			"missing resource",
+			false,
+			nil,
+			"Available",
+			"True",
+			true,
+		},
+	}
+
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := make([]runtime.Object, 0)
+			if tc.exists {
+				deployment := testDeployment("conditional", 1, 1)
+				_ = unstructured.SetNestedSlice(deployment.Object, tc.conditions, "status", "conditions")
+				objects = append(objects, deployment)
+			}
+
+			dc := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+			client := &K8sClients{DynamicClient: dc, RESTMapper: testRESTMapper()}
+
+			err := client.WaitForCondition(context.TODO(), gvk, "default", "conditional", tc.conditionType, tc.status, 100*time.Millisecond)
+			if tc.errors {
+				assert.Error(t, err, "Expected a timeout waiting for the condition.")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error waiting for a condition that's already met.")
+		})
+	}
+}
+
+func TestWaitForDeletion(t *testing.T) {
+	client, err := NewK8sClients()
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	ctx := context.TODO()
+	namespace := fmt.Sprintf("utility-client-delete-%d", time.Now().Unix())
+
+	err = client.EnsureNamespaceExists(ctx, namespace)
+	if err != nil {
+		t.Fatalf("failed creating namespace %s: %s", namespace, err)
+	}
+
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	interfaces := []dynamic.ResourceInterface{client.DynamicClient.Resource(gvr)}
+	objects := []*unstructured.Unstructured{{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": namespace,
+		},
+	}}}
+
+	fmt.Printf("Deleting namespace %s.\n", namespace)
+	err = client.DeleteResources(ctx, interfaces, objects)
+	if err != nil {
+		t.Fatalf("failed deleting namespace %s: %s", namespace, err)
+	}
+
+	err = client.WaitForDeletion(ctx, interfaces, objects, 2*time.Minute)
+	assert.NoError(t, err, "Namespace %s was not deleted.", namespace)
+}
+
+func TestWaitForReady(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+	}{
+		{
+			"basic resource",
+			"test_fixtures/resources.yaml",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Creating resources in k8s.\n")
+			err = client.ApplyResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Errorf("failed to apply resources: %s", err)
+			}
+
+			fmt.Printf("Waiting for resources to become ready.\n")
+			err = client.WaitForReady(ctx, interfaces, objects, 2*time.Minute)
+			assert.NoError(t, err, "Resources did not become ready.")
+
+			fmt.Printf("Cleaning up resources in k8s.\n")
+			err = client.DeleteResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Errorf("failed deleting resources: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"sync"
+)
+
+// warningCollector  The default rest.WarningHandler.  Keeps each distinct warning the API server sends, such as "apps/v1beta1 Deployment is deprecated", so callers can check for them after an operation.  See Warnings().
+type warningCollector struct {
+	sync.Mutex
+	clients  *K8sClients
+	warnings []string
+}
+
+// HandleWarningHeader  Records a warning, and logs it the first time it's seen.
+func (w *warningCollector) HandleWarningHeader(code int, agent string, text string) {
+	// the API server only sends warnings with code 299.  Anything else isn't meant for us, just like client-go's own handlers.
+	if code != 299 || text == "" {
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	// the same deprecation comes back on every request for that kind, so only keep it once
+	for _, warning := range w.warnings {
+		if warning == text {
+			return
+		}
+	}
+
+	w.warnings = append(w.warnings, text)
+	w.clients.log().Info("Warning from API server", "warning", text)
+}
+
+// Warnings  Returns the distinct warnings, such as API deprecations, the API server has sent since the client was created or ClearWarnings() was last called.  Always empty when ClientOptions.WarningHandler is set, since the warnings go there instead.
+func (k *K8sClients) Warnings() (warnings []string) {
+	warnings = make([]string, 0)
+	if k.warnings == nil {
+		return warnings
+	}
+
+	k.warnings.Lock()
+	defer k.warnings.Unlock()
+
+	warnings = append(warnings, k.warnings.warnings...)
+
+	return warnings
+}
+
+// ClearWarnings  Forgets the warnings collected so far, e.g. to see just the warnings from the next operation.
+func (k *K8sClients) ClearWarnings() {
+	if k.warnings == nil {
+		return
+	}
+
+	k.warnings.Lock()
+	defer k.warnings.Unlock()
+
+	k.warnings.warnings = nil
+}
@@ -0,0 +1,115 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recordingWarningHandler  A rest.WarningHandler that remembers every warning it's handed.
+type recordingWarningHandler struct {
+	warnings []string
+}
+
+func (r *recordingWarningHandler) HandleWarningHeader(code int, agent string, text string) {
+	r.warnings = append(r.warnings, text)
+}
+
+func TestWarnings(t *testing.T) {
+	deprecation := "v1 Namespace is deprecated in v99+, unavailable in v100+"
+
+	testCases := []struct {
+		name      string
+		handler   *recordingWarningHandler
+		collected []string
+		handled   []string
+	}{
+		{
+			"collected by default",
+			nil,
+			[]string{deprecation},
+			nil,
+		},
+		{
+			"custom handler",
+			&recordingWarningHandler{},
+			[]string{},
+			[]string{deprecation, deprecation},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Warning", `299 - "`+deprecation+`"`)
+		_, _ = w.Write([]byte(`{"kind": "Namespace", "apiVersion": "v1", "metadata": {"name": "default"}}`))
+	}))
+	defer server.Close()
+
+	testKubeconfig(t, server.URL)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := ClientOptions{ClusterMode: CLUSTER_MODE_OUT_OF_CLUSTER}
+			if tc.handler != nil {
+				opts.WarningHandler = tc.handler
+			}
+
+			client, err := NewK8sClientsWithOptions(opts)
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			// twice, since the server repeats itself on every request
+			for i := 0; i < 2; i++ {
+				_, err = client.ClientSet.CoreV1().Namespaces().Get(context.TODO(), "default", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("failed getting namespace: %s", err)
+				}
+			}
+
+			assert.Equal(t, tc.collected, client.Warnings(), "Collected warnings do not match expectations.")
+			if tc.handler != nil {
+				assert.Equal(t, tc.handled, tc.handler.warnings, "Handled warnings do not match expectations.")
+			}
+
+			client.ClearWarnings()
+			assert.Empty(t, client.Warnings(), "Warnings were not cleared.")
+		})
+	}
+}
+
+func TestWarningsSuppressed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Warning", `299 - "ignore me"`)
+		_, _ = w.Write([]byte(`{"kind": "Namespace", "apiVersion": "v1", "metadata": {"name": "default"}}`))
+	}))
+	defer server.Close()
+
+	testKubeconfig(t, server.URL)
+
+	client, err := NewK8sClientsWithOptions(ClientOptions{ClusterMode: CLUSTER_MODE_OUT_OF_CLUSTER, WarningHandler: rest.NoWarnings{}})
+	if err != nil {
+		t.Fatalf("failed creating client: %s", err)
+	}
+
+	_, err = client.ClientSet.CoreV1().Namespaces().Get(context.TODO(), "default", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed getting namespace: %s", err)
+	}
+
+	assert.Empty(t, client.Warnings(), "Suppressed warnings should not be collected.")
+}
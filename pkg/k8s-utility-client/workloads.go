@@ -0,0 +1,207 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"time"
+)
+
+// Scale  Sets the replica count of a Deployment, StatefulSet, or ReplicaSet via its scale subresource, like `kubectl scale`.  Other kinds are an error.
+func (k *K8sClients) Scale(ctx context.Context, namespace string, name string, kind string, replicas int32) (err error) {
+	switch kind {
+	case "Deployment", "StatefulSet", "ReplicaSet":
+	default:
+		err = errors.New(fmt.Sprintf("cannot scale %s kind %s.  Only Deployments, StatefulSets, and ReplicaSets are supported", name, kind))
+		return err
+	}
+
+	var scale *autoscalingv1.Scale
+
+	apps := k.ClientSet.AppsV1()
+
+	switch kind {
+	case "Deployment":
+		scale, err = apps.Deployments(namespace).GetScale(ctx, name, metav1.GetOptions{})
+	case "StatefulSet":
+		scale, err = apps.StatefulSets(namespace).GetScale(ctx, name, metav1.GetOptions{})
+	case "ReplicaSet":
+		scale, err = apps.ReplicaSets(namespace).GetScale(ctx, name, metav1.GetOptions{})
+	}
+
+	if err != nil {
+		err = errors.Wrapf(err, "failed getting scale of %s kind %s", name, kind)
+		return err
+	}
+
+	scale.Spec.Replicas = replicas
+
+	switch kind {
+	case "Deployment":
+		_, err = apps.Deployments(namespace).UpdateScale(ctx, name, scale, metav1.UpdateOptions{})
+	case "StatefulSet":
+		_, err = apps.StatefulSets(namespace).UpdateScale(ctx, name, scale, metav1.UpdateOptions{})
+	case "ReplicaSet":
+		_, err = apps.ReplicaSets(namespace).UpdateScale(ctx, name, scale, metav1.UpdateOptions{})
+	}
+
+	if err != nil {
+		err = errors.Wrapf(err, "failed scaling %s kind %s to %d replicas", name, kind, replicas)
+		return err
+	}
+
+	return err
+}
+
+// RESTARTED_AT_ANNOTATION  The pod template annotation `kubectl rollout restart` sets to trigger a new rollout.
+const RESTARTED_AT_ANNOTATION = "kubectl.kubernetes.io/restartedAt"
+
+// RolloutRestart  Triggers a new rollout of a Deployment, StatefulSet, or DaemonSet, like `kubectl rollout restart`, by stamping the current time on its pod template.  Other kinds are an error.
+func (k *K8sClients) RolloutRestart(ctx context.Context, namespace string, name string, kind string) (err error) {
+	switch kind {
+	case "Deployment", "StatefulSet", "DaemonSet":
+	default:
+		err = errors.New(fmt.Sprintf("cannot restart %s kind %s.  Only Deployments, StatefulSets, and DaemonSets are supported", name, kind))
+		return err
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						RESTARTED_AT_ANNOTATION: time.Now().Format(time.RFC3339),
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		err = errors.Wrapf(err, "failed creating restart patch")
+		return err
+	}
+
+	apps := k.ClientSet.AppsV1()
+
+	switch kind {
+	case "Deployment":
+		_, err = apps.Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case "StatefulSet":
+		_, err = apps.StatefulSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	case "DaemonSet":
+		_, err = apps.DaemonSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	}
+
+	if err != nil {
+		err = errors.Wrapf(err, "failed restarting %s kind %s", name, kind)
+		return err
+	}
+
+	return err
+}
+
+// RolloutStatus  Waits for a Deployment's rollout to finish, like `kubectl rollout status`.  The rollout is done when the controller has seen the latest spec, every desired replica is updated and available, and no old replicas are left.  On timeout the error describes where the rollout got stuck.
+func (k *K8sClients) RolloutStatus(ctx context.Context, namespace string, name string, timeout time.Duration) (err error) {
+	status := "not yet checked"
+
+	err = wait.PollImmediateWithContext(ctx, POLL_INTERVAL, timeout, func(ctx context.Context) (done bool, err error) {
+		deployment, err := k.ClientSet.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			err = errors.Wrapf(err, "failed getting deployment %s", name)
+			return false, err
+		}
+
+		done, status, err = deploymentRolloutStatus(deployment)
+
+		return done, err
+	})
+
+	if err == wait.ErrWaitTimeout {
+		if ctx.Err() != nil {
+			err = errors.Wrapf(ctx.Err(), "stopped waiting for rollout of deployment %s: %s", name, status)
+			return err
+		}
+
+		err = errors.New(fmt.Sprintf("timed out after %s waiting for rollout of deployment %s: %s", timeout, name, status))
+		return err
+	}
+
+	return err
+}
+
+// RestartAndWait  Restarts a Deployment, StatefulSet, or DaemonSet with RolloutRestart, then waits for the new rollout to finish, i.e. bounces it and makes sure it came back.  Deployments are waited on with RolloutStatus, the others with WaitForReady.  The timeout covers the wait, not the restart.
+func (k *K8sClients) RestartAndWait(ctx context.Context, namespace string, name string, kind string, timeout time.Duration) (err error) {
+	err = k.RolloutRestart(ctx, namespace, name, kind)
+	if err != nil {
+		return err
+	}
+
+	if kind == "Deployment" {
+		return k.RolloutStatus(ctx, namespace, name, timeout)
+	}
+
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: kind}
+
+	ri, err := k.resourceInterface(gvk, namespace)
+	if err != nil {
+		return err
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+
+	return k.WaitForReady(ctx, []dynamic.ResourceInterface{ri}, []*unstructured.Unstructured{obj}, timeout)
+}
+
+// deploymentRolloutStatus  Reports whether a Deployment's rollout is complete, and describes its progress if not.  A rollout that has exceeded its progress deadline is an error.
+func deploymentRolloutStatus(deployment *appsv1.Deployment) (done bool, status string, err error) {
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return false, "waiting for the deployment spec update to be observed", err
+	}
+
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			err = errors.New(fmt.Sprintf("deployment %s exceeded its progress deadline", deployment.Name))
+			return false, "progress deadline exceeded", err
+		}
+	}
+
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+
+	if deployment.Status.UpdatedReplicas < desired {
+		return false, fmt.Sprintf("%d of %d replicas updated", deployment.Status.UpdatedReplicas, desired), err
+	}
+
+	if deployment.Status.Replicas > deployment.Status.UpdatedReplicas {
+		return false, fmt.Sprintf("%d old replicas pending termination", deployment.Status.Replicas-deployment.Status.UpdatedReplicas), err
+	}
+
+	if deployment.Status.AvailableReplicas < desired || deployment.Status.UnavailableReplicas > 0 {
+		return false, fmt.Sprintf("%d of %d updated replicas available", deployment.Status.AvailableReplicas, desired), err
+	}
+
+	return true, "rolled out", err
+}
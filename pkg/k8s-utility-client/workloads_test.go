@@ -0,0 +1,357 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+	"time"
+)
+
+func TestUnsupportedKinds(t *testing.T) {
+	client := &K8sClients{}
+
+	err := client.Scale(context.TODO(), "default", "nginx", "DaemonSet", 2)
+	assert.Error(t, err, "Scaling a DaemonSet should be an error.")
+
+	err = client.RolloutRestart(context.TODO(), "default", "nginx", "ReplicaSet")
+	assert.Error(t, err, "Restarting a ReplicaSet should be an error.")
+}
+
+func TestScale(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+		replicas int32
+	}{
+		{
+			"scale up",
+			"test_fixtures/resources.yaml",
+			2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Creating resources in k8s.\n")
+			err = client.ApplyResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed to apply resources: %s", err)
+			}
+
+			defer func() {
+				fmt.Printf("Cleaning up resources in k8s.\n")
+				err = client.DeleteResources(ctx, interfaces, objects)
+				if err != nil {
+					t.Errorf("failed deleting resources: %s", err)
+				}
+			}()
+
+			err = client.Scale(ctx, "default", "nginx", "Deployment", tc.replicas)
+			if err != nil {
+				t.Fatalf("failed scaling deployment: %s", err)
+			}
+
+			deployment, err := client.ClientSet.AppsV1().Deployments("default").Get(ctx, "nginx", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed getting deployment: %s", err)
+			}
+
+			if assert.NotNil(t, deployment.Spec.Replicas, "Deployment has no replica count.") {
+				assert.Equal(t, tc.replicas, *deployment.Spec.Replicas, "Deployment was not scaled.")
+			}
+		})
+	}
+}
+
+func TestRolloutRestart(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+	}{
+		{
+			"deployment",
+			"test_fixtures/resources.yaml",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Creating resources in k8s.\n")
+			err = client.ApplyResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed to apply resources: %s", err)
+			}
+
+			defer func() {
+				fmt.Printf("Cleaning up resources in k8s.\n")
+				err = client.DeleteResources(ctx, interfaces, objects)
+				if err != nil {
+					t.Errorf("failed deleting resources: %s", err)
+				}
+			}()
+
+			restartedAt := make([]string, 0)
+
+			for i := 0; i < 2; i++ {
+				err = client.RolloutRestart(ctx, "default", "nginx", "Deployment")
+				if err != nil {
+					t.Fatalf("failed restarting deployment: %s", err)
+				}
+
+				deployment, err := client.ClientSet.AppsV1().Deployments("default").Get(ctx, "nginx", metav1.GetOptions{})
+				if err != nil {
+					t.Fatalf("failed getting deployment: %s", err)
+				}
+
+				annotation, ok := deployment.Spec.Template.Annotations[RESTARTED_AT_ANNOTATION]
+				assert.True(t, ok, "Restart annotation is missing from the pod template.")
+
+				restartedAt = append(restartedAt, annotation)
+
+				// the annotation has one second resolution
+				time.Sleep(2 * time.Second)
+			}
+
+			assert.NotEqual(t, restartedAt[0], restartedAt[1], "Restart annotation did not change between restarts.")
+		})
+	}
+}
+
+func TestRestartAndWait(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+	}{
+		{
+			"deployment",
+			"test_fixtures/resources.yaml",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Creating resources in k8s.\n")
+			err = client.ApplyResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed to apply resources: %s", err)
+			}
+
+			defer func() {
+				fmt.Printf("Cleaning up resources in k8s.\n")
+				err = client.DeleteResources(ctx, interfaces, objects)
+				if err != nil {
+					t.Errorf("failed deleting resources: %s", err)
+				}
+			}()
+
+			err = client.RolloutStatus(ctx, "default", "nginx", 2*time.Minute)
+			if err != nil {
+				t.Fatalf("initial rollout never finished: %s", err)
+			}
+
+			before, err := client.ClientSet.CoreV1().Pods("default").List(ctx, metav1.ListOptions{LabelSelector: "app=nginx"})
+			if err != nil {
+				t.Fatalf("failed listing pods: %s", err)
+			}
+
+			oldPods := make(map[string]bool)
+			for _, pod := range before.Items {
+				oldPods[pod.Name] = true
+			}
+
+			err = client.RestartAndWait(ctx, "default", "nginx", "Deployment", 2*time.Minute)
+			if err != nil {
+				t.Fatalf("failed restarting deployment: %s", err)
+			}
+
+			after, err := client.ClientSet.CoreV1().Pods("default").List(ctx, metav1.ListOptions{LabelSelector: "app=nginx"})
+			if err != nil {
+				t.Fatalf("failed listing pods: %s", err)
+			}
+
+			newReady := 0
+			for _, pod := range after.Items {
+				if oldPods[pod.Name] || pod.DeletionTimestamp != nil {
+					continue
+				}
+
+				for _, condition := range pod.Status.Conditions {
+					if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+						newReady++
+					}
+				}
+			}
+
+			assert.True(t, newReady > 0, "No new pods were ready after the restart.")
+		})
+	}
+}
+
+func TestDeploymentRolloutStatus(t *testing.T) {
+	three := int32(3)
+
+	testCases := []struct {
+		name     string
+		status   appsv1.DeploymentStatus
+		done     bool
+		contains string
+		errors   bool
+	}{
+		{
+			"complete",
+			appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3},
+			true,
+			"rolled out",
+			false,
+		},
+		{
+			"generation not observed",
+			appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3},
+			false,
+			"spec update",
+			false,
+		},
+		{
+			"partially updated",
+			appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 2, AvailableReplicas: 3},
+			false,
+			"2 of 3 replicas updated",
+			false,
+		},
+		{
+			"old replicas terminating",
+			appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 3, AvailableReplicas: 3},
+			false,
+			"1 old replicas pending termination",
+			false,
+		},
+		{
+			"not yet available",
+			appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 1, UnavailableReplicas: 2},
+			false,
+			"1 of 3 updated replicas available",
+			false,
+		},
+		{
+			"deadline exceeded",
+			appsv1.DeploymentStatus{ObservedGeneration: 2, Conditions: []appsv1.DeploymentCondition{{Type: appsv1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded"}}},
+			false,
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "nginx", Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: &three},
+				Status:     tc.status,
+			}
+
+			done, status, err := deploymentRolloutStatus(deployment)
+			if tc.errors {
+				assert.Error(t, err, "Expected an error.")
+				return
+			}
+
+			assert.NoError(t, err, "Unexpected error checking rollout status.")
+			assert.Equal(t, tc.done, done, "Unexpected rollout state.")
+			assert.Contains(t, status, tc.contains, "Unexpected rollout status description.")
+		})
+	}
+}
+
+func TestRolloutStatus(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fileName string
+	}{
+		{
+			"deployment",
+			"test_fixtures/resources.yaml",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewK8sClients()
+			if err != nil {
+				t.Fatalf("failed creating client: %s", err)
+			}
+
+			interfaces, objects, err := client.ResourcesAndObjectsFromFile(tc.fileName)
+			if err != nil {
+				t.Fatalf("failed to load yaml file %s: %s", tc.fileName, err)
+			}
+
+			ctx := context.TODO()
+
+			fmt.Printf("Creating resources in k8s.\n")
+			err = client.ApplyResources(ctx, interfaces, objects)
+			if err != nil {
+				t.Fatalf("failed to apply resources: %s", err)
+			}
+
+			defer func() {
+				fmt.Printf("Cleaning up resources in k8s.\n")
+				err = client.DeleteResources(ctx, interfaces, objects)
+				if err != nil {
+					t.Errorf("failed deleting resources: %s", err)
+				}
+			}()
+
+			err = client.RolloutStatus(ctx, "default", "nginx", 2*time.Minute)
+			assert.NoError(t, err, "Deployment did not finish rolling out.")
+		})
+	}
+}
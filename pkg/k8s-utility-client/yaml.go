@@ -0,0 +1,43 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// ObjectsToYAML  Serializes objects back into a multi-document yaml manifest, with each document starting with '---'.  Handy for showing what's about to be applied, or saving a rendered manifest.  The output loads back in with ResourcesAndObjectsFromBytes.
+func ObjectsToYAML(objects []*unstructured.Unstructured) (yamlBytes []byte, err error) {
+	var buf bytes.Buffer
+
+	for i, obj := range objects {
+		if obj == nil {
+			err = errors.New(fmt.Sprintf("object %d: empty", i))
+			return yamlBytes, err
+		}
+
+		doc, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			err = errors.Wrapf(err, "failed serializing %s kind %s", obj.GetName(), obj.GetKind())
+			return yamlBytes, err
+		}
+
+		buf.WriteString("---\n")
+		buf.Write(doc)
+	}
+
+	yamlBytes = buf.Bytes()
+
+	return yamlBytes, err
+}
@@ -0,0 +1,62 @@
+/*
+Copyright <2022> Nik Ogura <nik.ogura@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+package k8s_utility_client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"os"
+	"testing"
+)
+
+func TestObjectsToYAML(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fixture string
+	}{
+		{
+			"configmap",
+			"test_fixtures/configmap.yaml",
+		},
+		{
+			"multiple documents",
+			"test_fixtures/resources.yaml",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &K8sClients{DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), RESTMapper: testRESTMapper()}
+
+			manifest, err := os.ReadFile(tc.fixture)
+			if err != nil {
+				t.Fatalf("failed reading %s: %s", tc.fixture, err)
+			}
+
+			_, expected, err := client.ResourcesAndObjectsFromBytes(manifest)
+			if err != nil {
+				t.Fatalf("failed to load manifest: %s", err)
+			}
+
+			serialized, err := ObjectsToYAML(expected)
+			if err != nil {
+				t.Fatalf("failed serializing objects: %s", err)
+			}
+
+			_, actual, err := client.ResourcesAndObjectsFromBytes(serialized)
+			if err != nil {
+				t.Fatalf("failed to reload serialized objects: %s", err)
+			}
+
+			assert.Equal(t, expected, actual, "Reloaded objects do not match the originals.")
+		})
+	}
+}